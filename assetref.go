@@ -10,23 +10,27 @@ type AssetRef struct {
 func ParseAssetRef(dataReader *DataReader, format uint32, isLittleEndian bool) (*AssetRef, error) {
 	assetRef := AssetRef{}
 
-	assetPath, err := dataReader.ReadStringNull(256)
-	if err != nil {
-		return nil, err
+	if format >= 6 {
+		assetPath, err := dataReader.ReadStringNull(256)
+		if err != nil {
+			return nil, err
+		}
+		assetRef.AssetPath = assetPath
 	}
-	assetRef.AssetPath = assetPath
 
-	guid, err := dataReader.ReadBytes(16, isLittleEndian)
-	if err != nil {
-		return nil, err
-	}
-	assetRef.GUID = guid
+	if format >= 5 {
+		guid, err := dataReader.ReadBytes(16, isLittleEndian)
+		if err != nil {
+			return nil, err
+		}
+		assetRef.GUID = guid
 
-	assetRefType, err := dataReader.ReadInt(isLittleEndian)
-	if err != nil {
-		return nil, err
+		assetRefType, err := dataReader.ReadInt(isLittleEndian)
+		if err != nil {
+			return nil, err
+		}
+		assetRef.Type = assetRefType
 	}
-	assetRef.Type = assetRefType
 
 	filePath, err := dataReader.ReadStringNull(256)
 	if err != nil {
@@ -1,13 +1,14 @@
 package unity
 
-// "errors"
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"io/ioutil"
-	"os"
 	"path"
+	"sort"
 	"strings"
-
-	"github.com/k0kubun/pp"
+	"sync"
 )
 
 const (
@@ -17,10 +18,28 @@ const (
 	// SignatureUnityRaw Header Signature: UnityRaw
 	SignatureUnityRaw = "UnityRaw"
 
+	// SignatureUnityArchive Header Signature: UnityArchive
+	// UnityRawと同じヘッダと無圧縮のノード一覧を持つ
+	SignatureUnityArchive = "UnityArchive"
+
 	// SignatureUnityFS Header Signature: UnityFS
 	SignatureUnityFS = "UnityFS"
 )
 
+const (
+	// BundleFlagBlocksAndDirectoryInfoCombined ブロック情報にノード一覧を含む
+	BundleFlagBlocksAndDirectoryInfoCombined = 0x40
+
+	// BundleFlagBlocksInfoAtTheEnd ブロック情報がファイル末尾にある
+	BundleFlagBlocksInfoAtTheEnd = 0x80
+
+	// BundleFlagOldWebPluginCompatibility 旧Webプラグイン互換
+	BundleFlagOldWebPluginCompatibility = 0x100
+
+	// BundleFlagBlockInfoNeedPaddingAtStart ブロック情報の後に16バイト境界のパディングがある (2020+)
+	BundleFlagBlockInfoNeedPaddingAtStart = 0x200
+)
+
 type compressionType int
 
 const (
@@ -37,9 +56,30 @@ const (
 	CompressionTypeLZ4HC
 
 	// CompressionTypeLZHAM Compression Type: LZHAM https://github.com/richgel999/lzham_codec
+	// 展開、圧縮ともに未対応で、ErrUnsupportedCompressionTypeになる
 	CompressionTypeLZHAM
 )
 
+var compressionTypeNames = []string{"none", "lzma", "lz4", "lz4hc", "lzham"}
+
+func (c compressionType) String() string {
+	if c >= 0 && int(c) < len(compressionTypeNames) {
+		return compressionTypeNames[c]
+	}
+	return fmt.Sprintf("compressionType(%d)", int(c))
+}
+
+// ParseCompressionType 圧縮形式の名前 (none, lzma, lz4, lz4hc) から圧縮形式を取得
+// 対応していない形式 (lzham) はErrUnsupportedCompressionTypeを返す
+func ParseCompressionType(name string) (compressionType, error) {
+	for i, n := range compressionTypeNames {
+		if strings.EqualFold(n, name) && compressionType(i).isSupported() {
+			return compressionType(i), nil
+		}
+	}
+	return CompressionTypeNone, ErrUnsupportedCompressionType
+}
+
 type Bundle struct {
 	Binary          []byte
 	Signature       string
@@ -55,6 +95,39 @@ type Bundle struct {
 	Blocks          []FSBlock
 	NodeStartAt     int64
 	Nodes           []FSNode
+
+	// UnityWeb / UnityRaw / UnityArchive のみ
+	Hash                     []byte
+	CRC                      uint32
+	MinimumStreamedBytes     uint32
+	HeaderSize               uint32
+	NumLevelsBeforeStreaming uint32
+	Levels                   []LevelInfo
+	FileInfoHeaderSize       uint32
+
+	reader     io.ReaderAt
+	size       int64
+	blocks     []bundleBlock
+	cacheMu    sync.Mutex
+	cacheIndex int
+	cacheData  []byte
+}
+
+// bundleHeaderReadSize OpenBundle時にヘッダとして読み込む最大サイズ
+const bundleHeaderReadSize = 64 * 1024
+
+// bundleBlock ブロックのファイル上の位置と展開後データ上の位置
+type bundleBlock struct {
+	FSBlock
+	fileOffset int64
+	dataOffset int64
+	lzmaAlone  bool
+}
+
+// LevelInfo 旧形式Bundleのレベル終端位置
+type LevelInfo struct {
+	CompressedEnd   uint32
+	UncompressedEnd uint32
 }
 
 // FSBlock Block
@@ -72,31 +145,49 @@ type FSNode struct {
 	Name   string
 }
 
-func parseBundle533(dataReader *DataReader, assetBundle *Bundle) error {
-	panic(ErrNotImplemented)
-}
-
-func parseBundle534(dataReader *DataReader, assetBundle *Bundle) error {
-	assetBundle.NodeStartAt = assetBundle.FileSize - int64(dataReader.Len())
+// parseBundleFS UnityFS形式のBundleをパース
+func parseBundleFS(dataReader *DataReader, r io.ReaderAt, size int64, assetBundle *Bundle) error {
+	// 2019.4以降 (FormatVersion 7+) はヘッダの後が16バイト境界に揃えられる
+	// FormatVersion 6の2019.4にも揃えているものがあるため、境界までが全て0の場合は読み飛ばす (AssetStudioと同じ判定)
+	pos := dataReader.Pos()
+	if assetBundle.FormatVersion >= 7 {
+		pos = alignOffset(pos, 16)
+	} else if strings.HasPrefix(assetBundle.PlayerVersion, "2019.4.") {
+		padding, err := dataReader.ReadBytes(int(alignOffset(pos, 16)-pos), false)
+		if err == nil && bytes.Count(padding, []byte{0}) == len(padding) {
+			pos = dataReader.Pos()
+		}
+	}
 
-	var compDataReader *DataReader
+	blockInfoAt := pos
+	if assetBundle.Flags&BundleFlagBlocksInfoAtTheEnd != 0 {
+		blockInfoAt = assetBundle.FileSize - int64(assetBundle.CiBlockSize)
+	} else {
+		pos += int64(assetBundle.CiBlockSize)
+	}
 
-	if assetBundle.CompressionType == CompressionTypeNone {
-		blockPos := assetBundle.FileSize - int64(assetBundle.CiBlockSize)
+	err := checkFileRange(blockInfoAt, int64(assetBundle.CiBlockSize), size)
+	if err != nil {
+		return err
+	}
+	compBlockInfo, err := readAt(r, blockInfoAt, int(assetBundle.CiBlockSize))
+	if err != nil {
+		return err
+	}
 
-		_, err := dataReader.Seek(blockPos, os.SEEK_SET)
-		if err != nil {
-			return err
-		}
+	if assetBundle.Flags&BundleFlagBlockInfoNeedPaddingAtStart != 0 {
+		pos = alignOffset(pos, 16)
+	}
+	assetBundle.NodeStartAt = pos
 
-		compDataReader, err = dataReader.ReNew(int(assetBundle.CiBlockSize), false)
-		if err != nil {
-			return err
-		}
+	blockInfo, err := decompressBlock(compBlockInfo, int(assetBundle.UIBlockSize), assetBundle.CompressionType)
+	if err != nil {
+		return err
 	}
 
-	if compDataReader == nil {
-		return ErrUnsupportedCompressionType
+	compDataReader, err := NewDataReader(blockInfo)
+	if err != nil {
+		return err
 	}
 
 	guid, err := compDataReader.ReadBytes(16, false)
@@ -110,14 +201,15 @@ func parseBundle534(dataReader *DataReader, assetBundle *Bundle) error {
 		return err
 	}
 
+	// ブロックごとに展開後のサイズ、圧縮後のサイズ、フラグの順
 	blocks := []FSBlock{}
 	for i := 0; i < int(numBlocks); i++ {
-		bcSize, err := compDataReader.ReadInt(false)
+		buSize, err := compDataReader.ReadInt(false)
 		if err != nil {
 			return err
 		}
 
-		buSize, err := compDataReader.ReadInt(false)
+		bcSize, err := compDataReader.ReadInt(false)
 		if err != nil {
 			return err
 		}
@@ -136,6 +228,8 @@ func parseBundle534(dataReader *DataReader, assetBundle *Bundle) error {
 	}
 	assetBundle.Blocks = blocks
 
+	// BundleFlagBlocksAndDirectoryInfoCombinedはUnity 5.3以降の全てのUnityFSで立っている
+	// 立っていないレイアウトは公開された資料が無く、AssetStudioやUnityPyもフラグを見ずにブロック情報の後にノード一覧を読むため同様に扱う
 	numNodes, err := compDataReader.ReadInt(false)
 	if err != nil {
 		return err
@@ -173,19 +267,342 @@ func parseBundle534(dataReader *DataReader, assetBundle *Bundle) error {
 	}
 	assetBundle.Nodes = nodes
 
+	assetBundle.indexBlocks()
+
 	return nil
 }
 
-// ParseBundle Bundleをパース
-func ParseBundle(path string) (*Bundle, error) {
-	assetBundle := Bundle{}
-	b, err := ioutil.ReadFile(path)
+// indexBlocks 各ブロックのファイル上の位置と展開後データ上の位置を計算
+func (b *Bundle) indexBlocks() {
+	fileOffset := b.NodeStartAt
+	dataOffset := int64(0)
+	blocks := []bundleBlock{}
+	for _, block := range b.Blocks {
+		blocks = append(blocks, bundleBlock{
+			FSBlock:    block,
+			fileOffset: fileOffset,
+			dataOffset: dataOffset,
+		})
+		fileOffset += int64(uint32(block.BcSize))
+		dataOffset += int64(uint32(block.BuSize))
+	}
+	b.blocks = blocks
+	b.cacheIndex = -1
+}
+
+// blockData i番目のブロックを展開して返す。直近に展開したブロックはキャッシュする
+func (b *Bundle) blockData(i int) ([]byte, error) {
+	b.cacheMu.Lock()
+	defer b.cacheMu.Unlock()
+
+	if b.cacheIndex == i && b.cacheData != nil {
+		return b.cacheData, nil
+	}
+
+	block := b.blocks[i]
+	err := checkFileRange(block.fileOffset, int64(uint32(block.BcSize)), b.size)
+	if err != nil {
+		return nil, err
+	}
+	compData, err := readAt(b.reader, block.fileOffset, int(uint32(block.BcSize)))
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if block.lzmaAlone {
+		data, err = decompressLZMAAlone(compData)
+	} else {
+		data, err = decompressBlock(compData, int(uint32(block.BuSize)), compressionType(block.BFlags&blockCompressionMask))
+	}
 	if err != nil {
 		return nil, err
 	}
-	assetBundle.Binary = b
 
-	dataReader, err := NewDataReader(b)
+	b.cacheIndex = i
+	b.cacheData = data
+	return data, nil
+}
+
+// bundleDataReader 展開後のブロックデータ全体を表すio.ReaderAt
+type bundleDataReader struct {
+	b *Bundle
+}
+
+// ReadAt implements the io.ReaderAt interface.
+func (d bundleDataReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidNodeRange
+	}
+
+	blocks := d.b.blocks
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		i := sort.Search(len(blocks), func(i int) bool {
+			return blocks[i].dataOffset+int64(uint32(blocks[i].BuSize)) > pos
+		})
+		if i == len(blocks) {
+			return n, io.EOF
+		}
+
+		data, err := d.b.blockData(i)
+		if err != nil {
+			return n, err
+		}
+		start := pos - blocks[i].dataOffset
+		if start >= int64(len(data)) {
+			return n, ErrInvalidBlockSize
+		}
+		n += copy(p[n:], data[start:])
+	}
+	return n, nil
+}
+
+// NodeReader ノードの内容を読み込むio.SectionReaderを返す。ブロックは読み込み時に展開される
+func (b *Bundle) NodeReader(node FSNode) *io.SectionReader {
+	return io.NewSectionReader(bundleDataReader{b}, node.Offset, node.Size)
+}
+
+// ReadNode ノードの内容を全て読み込む
+func (b *Bundle) ReadNode(node FSNode) ([]byte, error) {
+	// 確保する前に展開後のデータ全体に収まるか確認する
+	err := checkFileRange(node.Offset, node.Size, b.dataSize())
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, node.Size)
+	if node.Size == 0 {
+		return data, nil
+	}
+	_, err = b.NodeReader(node).ReadAt(data, 0)
+	if err == io.EOF {
+		return nil, ErrInvalidNodeRange
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// dataSize 全ブロックの展開後のサイズの合計
+func (b *Bundle) dataSize() int64 {
+	if len(b.blocks) == 0 {
+		return 0
+	}
+	last := b.blocks[len(b.blocks)-1]
+	return last.dataOffset + int64(uint32(last.BuSize))
+}
+
+// checkFileRange offからnバイトがsize以内に収まるか確認する
+// ヘッダの値から読み込むサイズを決める場合は、確保する前にこれで確認する
+func checkFileRange(off, n, size int64) error {
+	if off < 0 || n < 0 || off > size || n > size-off {
+		return ErrInvalidNodeRange
+	}
+	return nil
+}
+
+// readAt rのoffからnバイト読み込む
+func readAt(r io.ReaderAt, off int64, n int) ([]byte, error) {
+	b := make([]byte, n)
+	read, err := r.ReadAt(b, off)
+	if read == n {
+		return b, nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return nil, err
+}
+
+// alignOffset offをnバイト境界に揃える (nは2の累乗)
+func alignOffset(off int64, n int64) int64 {
+	return (off + n - 1) &^ (n - 1)
+}
+
+// parseBundleLegacy UnityWeb / UnityRaw / UnityArchive形式のBundleをパース
+// UnityWebのみストリームがLZMAで圧縮されている
+func parseBundleLegacy(dataReader *DataReader, r io.ReaderAt, size int64, assetBundle *Bundle) error {
+	if assetBundle.FormatVersion >= 4 {
+		hash, err := dataReader.ReadBytes(16, false)
+		if err != nil {
+			return err
+		}
+		assetBundle.Hash = hash
+
+		crc, err := dataReader.ReadUint(false)
+		if err != nil {
+			return err
+		}
+		assetBundle.CRC = crc
+	}
+
+	minimumStreamedBytes, err := dataReader.ReadUint(false)
+	if err != nil {
+		return err
+	}
+	assetBundle.MinimumStreamedBytes = minimumStreamedBytes
+
+	headerSize, err := dataReader.ReadUint(false)
+	if err != nil {
+		return err
+	}
+	assetBundle.HeaderSize = headerSize
+
+	numLevelsBeforeStreaming, err := dataReader.ReadUint(false)
+	if err != nil {
+		return err
+	}
+	assetBundle.NumLevelsBeforeStreaming = numLevelsBeforeStreaming
+
+	numLevels, err := dataReader.ReadInt(false)
+	if err != nil {
+		return err
+	}
+
+	levels := []LevelInfo{}
+	for i := 0; i < int(numLevels); i++ {
+		compressedEnd, err := dataReader.ReadUint(false)
+		if err != nil {
+			return err
+		}
+
+		uncompressedEnd, err := dataReader.ReadUint(false)
+		if err != nil {
+			return err
+		}
+
+		levels = append(levels, LevelInfo{
+			CompressedEnd:   compressedEnd,
+			UncompressedEnd: uncompressedEnd,
+		})
+	}
+	assetBundle.Levels = levels
+
+	if assetBundle.FormatVersion >= 2 {
+		completeFileSize, err := dataReader.ReadUint(false)
+		if err != nil {
+			return err
+		}
+		assetBundle.FileSize = int64(completeFileSize)
+	}
+
+	if assetBundle.FormatVersion >= 3 {
+		fileInfoHeaderSize, err := dataReader.ReadUint(false)
+		if err != nil {
+			return err
+		}
+		assetBundle.FileInfoHeaderSize = fileInfoHeaderSize
+	}
+
+	if len(levels) == 0 {
+		return ErrInvalidAssetBundleType
+	}
+
+	assetBundle.NodeStartAt = int64(headerSize)
+
+	// 最後のレベルの終端が全レベルを含むストリームの終端
+	lastLevel := levels[len(levels)-1]
+	streamSize := int64(lastLevel.CompressedEnd)
+	if assetBundle.NodeStartAt+streamSize > size {
+		streamSize = size - assetBundle.NodeStartAt
+	}
+	if streamSize < 0 {
+		return ErrInvalidAssetBundleType
+	}
+
+	// 旧形式はストリーム全体を1つのブロックとして扱う
+	block := bundleBlock{
+		FSBlock: FSBlock{
+			BcSize: int32(streamSize),
+			BuSize: int32(streamSize),
+		},
+		fileOffset: assetBundle.NodeStartAt,
+	}
+	if assetBundle.Signature == SignatureUnityWeb {
+		assetBundle.CompressionType = CompressionTypeLZMA
+		block.BuSize = int32(lastLevel.UncompressedEnd)
+		block.BFlags = int16(CompressionTypeLZMA)
+		block.lzmaAlone = true
+	}
+	assetBundle.blocks = []bundleBlock{block}
+	assetBundle.cacheIndex = -1
+
+	stream, err := assetBundle.blockData(0)
+	if err != nil {
+		return err
+	}
+	// 展開後のサイズはヘッダの値ではなく実際のストリームに合わせる
+	assetBundle.blocks[0].BuSize = int32(len(stream))
+
+	streamReader, err := NewDataReader(stream)
+	if err != nil {
+		return err
+	}
+
+	numNodes, err := streamReader.ReadInt(false)
+	if err != nil {
+		return err
+	}
+
+	nodes := []FSNode{}
+	for i := 0; i < int(numNodes); i++ {
+		name, err := streamReader.ReadStringNull(256)
+		if err != nil {
+			return err
+		}
+
+		offset, err := streamReader.ReadUint(false)
+		if err != nil {
+			return err
+		}
+
+		size, err := streamReader.ReadUint(false)
+		if err != nil {
+			return err
+		}
+
+		node := FSNode{
+			Offset: int64(offset),
+			Size:   int64(size),
+			Name:   name,
+		}
+		nodes = append(nodes, node)
+	}
+	assetBundle.Nodes = nodes
+
+	return nil
+}
+
+// isBundleSignature Bundleのシグネチャかどうか
+func isBundleSignature(signature string) bool {
+	switch signature {
+	case SignatureUnityFS, SignatureUnityWeb, SignatureUnityRaw, SignatureUnityArchive:
+		return true
+	}
+	return false
+}
+
+// OpenBundle io.ReaderAtからBundleを開く
+// 開く時点ではヘッダとブロック情報のみを読み込み、各ブロックはノードの読み込み時に展開する
+func OpenBundle(r io.ReaderAt, size int64) (*Bundle, error) {
+	assetBundle := &Bundle{
+		reader: r,
+		size:   size,
+	}
+
+	headerSize := size
+	if headerSize > bundleHeaderReadSize {
+		headerSize = bundleHeaderReadSize
+	}
+	header, err := readAt(r, 0, int(headerSize))
+	if err != nil {
+		return nil, err
+	}
+
+	dataReader, err := NewDataReader(header)
 	if err != nil {
 		return nil, err
 	}
@@ -194,6 +611,10 @@ func ParseBundle(path string) (*Bundle, error) {
 	if err != nil {
 		return nil, err
 	}
+	// シリアライズファイル等を渡された場合に続くヘッダを読まずに判別できるようにする
+	if !isBundleSignature(signature) {
+		return nil, ErrInvalidAssetBundleType
+	}
 	assetBundle.Signature = signature
 
 	formatVersion, err := dataReader.ReadInt(false)
@@ -214,7 +635,8 @@ func ParseBundle(path string) (*Bundle, error) {
 	}
 	assetBundle.PlayerVersion = playerRevision
 
-	if signature == SignatureUnityFS {
+	switch signature {
+	case SignatureUnityFS:
 		fileSize, err := dataReader.ReadLong(false)
 		if err != nil {
 			return nil, err
@@ -239,183 +661,53 @@ func ParseBundle(path string) (*Bundle, error) {
 		}
 		assetBundle.Flags = flags
 
-		compressionType := compressionType(flags & 0x3F)
+		compressionType := compressionType(flags & blockCompressionMask)
 		assetBundle.CompressionType = compressionType
 
-		if strings.HasPrefix(assetBundle.PlayerVersion, "5.3.3p") {
-			err = parseBundle533(dataReader, &assetBundle)
-			if err != nil {
-				return nil, err
-			}
-		} else if strings.HasPrefix(assetBundle.PlayerVersion, "5.3.4p") {
-			err = parseBundle534(dataReader, &assetBundle)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			return nil, ErrUnsupportedPlayerVersion
+		err = parseBundleFS(dataReader, r, size, assetBundle)
+		if err != nil {
+			return nil, err
 		}
-
-		return &assetBundle, nil
+	case SignatureUnityWeb, SignatureUnityRaw, SignatureUnityArchive:
+		err = parseBundleLegacy(dataReader, r, size, assetBundle)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrInvalidAssetBundleType
 	}
 
-	panic(ErrNotImplemented)
+	return assetBundle, nil
 }
 
-func (b *Bundle) ExportAssets(dir string) error {
-	nodePos := int64(b.NodeStartAt)
+// ParseBundle Bundleをパース
+func ParseBundle(path string) (*Bundle, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
 
-	pp.Println("b.Nodes", b.Nodes)
+	assetBundle, err := OpenBundle(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	assetBundle.Binary = b
 
-	for _, node := range b.Nodes {
-		startAt := nodePos + node.Offset
-		endAt := startAt + node.Size
-		data := b.Binary[startAt:endAt]
+	return assetBundle, nil
+}
 
-		filePath := path.Join(dir, node.Name)
-		err := ioutil.WriteFile(filePath, data, 0644)
+// ExportAssets 各ノードをdir以下にファイルとして書き出す
+func (b *Bundle) ExportAssets(dir string) error {
+	for _, node := range b.Nodes {
+		data, err := b.ReadNode(node)
 		if err != nil {
 			return err
 		}
 
-		if b.Signature == SignatureUnityFS {
-			if !strings.HasSuffix(node.Name, ".resource") {
-				assetDataReader, err := NewDataReader(data)
-				if err != nil {
-					return err
-				}
-
-				metadataSize, err := assetDataReader.ReadUint(false)
-				if err != nil {
-					return err
-				}
-				pp.Println("metadataSize", metadataSize)
-
-				fileSize, err := assetDataReader.ReadUint(false)
-				if err != nil {
-					return err
-				}
-				pp.Println("fileSize", fileSize)
-
-				format, err := assetDataReader.ReadUint(false)
-				if err != nil {
-					return err
-				}
-				pp.Println("format", format)
-
-				dataOffset, err := assetDataReader.ReadUint(false)
-				if err != nil {
-					return err
-				}
-				pp.Println("dataOffset", dataOffset)
-
-				isLittleEndian := false
-				if format >= 9 {
-					endianness, err := assetDataReader.ReadUint(false)
-					if err != nil {
-						return err
-					}
-					pp.Println("endianness", endianness)
-
-					if endianness == 0 {
-						isLittleEndian = true
-					}
-				}
-				pp.Println("isLittleEndian", isLittleEndian)
-
-				// self.tree = TypeMetadata(self)
-				// self.tree.load(buf)
-				// typeMetadata, err := ParseTypeMetadata(assetDataReader, format, isLittleEndian)
-				_, err = ParseTypeMetadata(assetDataReader, format, isLittleEndian)
-				if err != nil {
-					return err
-				}
-				// pp.Println("TypeMetadata", typeMetadata)
-
-				isLongObjectIDs := false
-				if format >= 7 && format <= 13 {
-					longObjectIDsFlag, err := assetDataReader.ReadUint(isLittleEndian)
-					if err != nil {
-						return err
-					}
-					pp.Println("longObjectIDsFlag", longObjectIDsFlag)
-
-					if longObjectIDsFlag > 0 {
-						isLongObjectIDs = true
-					}
-				}
-				pp.Println("isLongObjectIDs", isLongObjectIDs)
-
-				numObjects, err := assetDataReader.ReadUint(isLittleEndian)
-				if err != nil {
-					return err
-				}
-				pp.Println("numObjects", numObjects)
-
-				for i := 0; i < int(numObjects); i++ {
-					obj, err := ParseObjectInfo(assetDataReader, format, isLongObjectIDs, isLittleEndian)
-					if err != nil {
-						return err
-					}
-					pp.Println("ObjectInfo", obj)
-					// TODO: self.register_object(obj)
-				}
-
-				if format >= 11 {
-					numAdds, err := assetDataReader.ReadUint(isLittleEndian)
-					if err != nil {
-						return err
-					}
-					pp.Println("numAdds", numAdds)
-
-					for i := 0; i < int(numAdds); i++ {
-						if format >= 14 {
-							err = assetDataReader.Align()
-							if err != nil {
-								return err
-							}
-						}
-
-						addID, err := assetDataReader.ReadLong(isLittleEndian)
-						if err != nil {
-							return err
-						}
-						pp.Println("addID", addID)
-
-						unk, err := assetDataReader.ReadInt(isLittleEndian)
-						if err != nil {
-							return err
-						}
-						pp.Println("unk", unk)
-					}
-				}
-
-				if format >= 6 {
-					numRefs, err := assetDataReader.ReadUint(isLittleEndian)
-					if err != nil {
-						return err
-					}
-
-					pp.Println("numRefs", numRefs)
-
-					for i := 0; i < int(numRefs); i++ {
-						assetRef, err := ParseAssetRef(assetDataReader, format, isLittleEndian)
-						if err != nil {
-							return err
-						}
-						pp.Println("AssetRef", assetRef)
-					}
-				}
-
-				unk_string, err := assetDataReader.ReadStringNull(256)
-				if err != nil {
-					return err
-				}
-				pp.Println("unk_string", unk_string)
-			}
-
-		} else {
-			panic(ErrNotImplemented)
+		filePath := path.Join(dir, node.Name)
+		err = ioutil.WriteFile(filePath, data, 0644)
+		if err != nil {
+			return err
 		}
 	}
 	return nil
@@ -0,0 +1,229 @@
+package unity
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Open implements the fs.FS interface.
+// ノード名の"/"区切りはディレクトリとして扱う
+func (b *Bundle) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if node, ok := b.lookupNode(name); ok {
+		return &bundleFile{
+			SectionReader: b.NodeReader(node),
+			info:          newBundleFileInfo(name, node),
+		}, nil
+	}
+
+	if entries, ok := b.dirEntries(name); ok {
+		return &bundleDir{
+			info:    newBundleDirInfo(name),
+			entries: entries,
+		}, nil
+	}
+
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir implements the fs.ReadDirFS interface.
+func (b *Bundle) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	entries, ok := b.dirEntries(name)
+	if !ok {
+		if _, isNode := b.lookupNode(name); isNode {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+		}
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return entries, nil
+}
+
+// Stat implements the fs.StatFS interface.
+func (b *Bundle) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if node, ok := b.lookupNode(name); ok {
+		return newBundleFileInfo(name, node), nil
+	}
+
+	if _, ok := b.dirEntries(name); ok {
+		return newBundleDirInfo(name), nil
+	}
+
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+var errNotDir = errors.New("not a directory")
+
+var errIsDir = errors.New("is a directory")
+
+// lookupNode 名前が一致するノードを探す
+func (b *Bundle) lookupNode(name string) (FSNode, bool) {
+	for _, node := range b.Nodes {
+		if node.Name == name {
+			return node, true
+		}
+	}
+	return FSNode{}, false
+}
+
+// dirEntries ディレクトリ直下のエントリを名前順に返す。ディレクトリが存在しない場合はfalse
+func (b *Bundle) dirEntries(dir string) ([]fs.DirEntry, bool) {
+	prefix := ""
+	if dir != "." {
+		prefix = dir + "/"
+	}
+
+	found := dir == "."
+	seen := map[string]bool{}
+	entries := []fs.DirEntry{}
+	for _, node := range b.Nodes {
+		if !strings.HasPrefix(node.Name, prefix) || !fs.ValidPath(node.Name) {
+			continue
+		}
+		found = true
+
+		rest := node.Name[len(prefix):]
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			sub := rest[:i]
+			if !seen[sub] {
+				seen[sub] = true
+				entries = append(entries, fs.FileInfoToDirEntry(newBundleDirInfo(prefix+sub)))
+			}
+			continue
+		}
+
+		if !seen[rest] {
+			seen[rest] = true
+			entries = append(entries, fs.FileInfoToDirEntry(newBundleFileInfo(node.Name, node)))
+		}
+	}
+
+	if !found {
+		return nil, false
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, true
+}
+
+// bundleFileInfo ノードまたは仮想ディレクトリのfs.FileInfo
+// Sys()はノードの場合FSNode (Statusを含む) を返す
+type bundleFileInfo struct {
+	name  string
+	node  FSNode
+	isDir bool
+}
+
+func newBundleFileInfo(name string, node FSNode) *bundleFileInfo {
+	return &bundleFileInfo{
+		name: path.Base(name),
+		node: node,
+	}
+}
+
+func newBundleDirInfo(name string) *bundleFileInfo {
+	return &bundleFileInfo{
+		name:  path.Base(name),
+		isDir: true,
+	}
+}
+
+func (fi *bundleFileInfo) Name() string {
+	return fi.name
+}
+
+func (fi *bundleFileInfo) Size() int64 {
+	if fi.isDir {
+		return 0
+	}
+	return fi.node.Size
+}
+
+func (fi *bundleFileInfo) Mode() fs.FileMode {
+	if fi.isDir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (fi *bundleFileInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (fi *bundleFileInfo) IsDir() bool {
+	return fi.isDir
+}
+
+func (fi *bundleFileInfo) Sys() interface{} {
+	if fi.isDir {
+		return nil
+	}
+	return fi.node
+}
+
+// bundleFile ノードのfs.File。io.ReaderAt / io.Seekerも実装する
+type bundleFile struct {
+	*io.SectionReader
+	info *bundleFileInfo
+}
+
+func (f *bundleFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *bundleFile) Close() error {
+	return nil
+}
+
+// bundleDir 仮想ディレクトリのfs.ReadDirFile
+type bundleDir struct {
+	info    *bundleFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *bundleDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *bundleDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errIsDir}
+}
+
+func (d *bundleDir) Close() error {
+	return nil
+}
+
+func (d *bundleDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
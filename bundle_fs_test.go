@@ -0,0 +1,37 @@
+package unity
+
+import (
+	"bytes"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestBundleFS(t *testing.T) {
+	nodes := append(testBundleNodes(), BundleNodeData{Name: "assets/sub/file.txt", Status: 0, Data: []byte("nested")})
+
+	var buf bytes.Buffer
+	err := WriteBundle(&buf, nodes, &BundleWriterOptions{CompressionType: CompressionTypeLZ4, BlockSize: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := OpenBundle(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fstest.TestFS(b, "CAB-0123456789abcdef", "CAB-0123456789abcdef.resS", "CAB-empty", "assets/sub/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := fs.Stat(b, "CAB-0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, ok := fi.Sys().(FSNode)
+	if !ok || node.Status != 4 || fi.Size() != int64(len(nodes[0].Data)) {
+		t.Fatalf("FileInfoにノード情報が含まれていません: %+v", fi.Sys())
+	}
+}
@@ -0,0 +1,465 @@
+package unity
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+func testBundleNodes() []BundleNodeData {
+	serialized := bytes.Repeat([]byte("CAB-serialized-file-data "), 20000)
+	resource := make([]byte, 300000)
+	for i := range resource {
+		resource[i] = byte(i * 7)
+	}
+	return []BundleNodeData{
+		{Name: "CAB-0123456789abcdef", Status: 4, Data: serialized},
+		{Name: "CAB-0123456789abcdef.resS", Status: 0, Data: resource},
+		{Name: "CAB-empty", Status: 4, Data: []byte{}},
+	}
+}
+
+func TestWriteBundleRoundTrip(t *testing.T) {
+	nodes := testBundleNodes()
+	guid := []byte("0123456789abcdef")
+
+	cases := []BundleWriterOptions{
+		{},
+		{CompressionType: CompressionTypeLZ4, BlockInfoCompressionType: CompressionTypeLZ4HC},
+		{CompressionType: CompressionTypeLZ4HC, Flags: BundleFlagBlocksInfoAtTheEnd},
+		{CompressionType: CompressionTypeLZMA, BlockInfoCompressionType: CompressionTypeLZMA},
+		{CompressionType: CompressionTypeLZ4, BlockSize: 1000, FormatVersion: 7, Flags: BundleFlagBlockInfoNeedPaddingAtStart},
+		{CompressionType: CompressionTypeLZMA, BlockSize: 65536, FormatVersion: 8, Flags: BundleFlagBlocksInfoAtTheEnd | BundleFlagBlockInfoNeedPaddingAtStart},
+	}
+
+	for i, opts := range cases {
+		opts.PlayerVersion = "2020.3.1f1"
+		opts.GUID = guid
+
+		var buf bytes.Buffer
+		err := WriteBundle(&buf, nodes, &opts)
+		if err != nil {
+			t.Fatalf("case %d: WriteBundle: %v", i, err)
+		}
+
+		b, err := OpenBundle(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("case %d: OpenBundle: %v", i, err)
+		}
+
+		if b.FileSize != int64(buf.Len()) {
+			t.Fatalf("case %d: FileSizeが一致しません: %d != %d", i, b.FileSize, buf.Len())
+		}
+		if !bytes.Equal(b.GUID, guid) {
+			t.Fatalf("case %d: GUIDが一致しません", i)
+		}
+		if len(b.Nodes) != len(nodes) {
+			t.Fatalf("case %d: ノード数が一致しません: %d", i, len(b.Nodes))
+		}
+		for j, node := range b.Nodes {
+			if node.Name != nodes[j].Name || node.Status != nodes[j].Status {
+				t.Fatalf("case %d: ノード情報が一致しません: %+v", i, node)
+			}
+			data, err := b.ReadNode(node)
+			if err != nil {
+				t.Fatalf("case %d: ReadNode: %v", i, err)
+			}
+			if !bytes.Equal(data, nodes[j].Data) {
+				t.Fatalf("case %d: %s の内容が一致しません", i, node.Name)
+			}
+		}
+	}
+}
+
+func TestWriteBundleMaxBlockSize(t *testing.T) {
+	defer func(size int64) { maxBundleBlockSize = size }(maxBundleBlockSize)
+	maxBundleBlockSize = 100000
+
+	// LZMAの既定 (全体で1ブロック) でも上限を超えるブロックは分割する
+	nodes := testBundleNodes()
+	var buf bytes.Buffer
+	err := WriteBundle(&buf, nodes, &BundleWriterOptions{CompressionType: CompressionTypeLZMA})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := OpenBundle(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Blocks) != 8 {
+		t.Fatalf("unexpected number of blocks: %d", len(b.Blocks))
+	}
+	for _, block := range b.Blocks {
+		if int64(block.BuSize) > maxBundleBlockSize {
+			t.Fatalf("block exceeds the maximum size: %+v", block)
+		}
+	}
+	for i, node := range b.Nodes {
+		data, err := b.ReadNode(node)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, nodes[i].Data) {
+			t.Fatalf("%s の内容が一致しません", node.Name)
+		}
+	}
+}
+
+func TestCompressionTypeLZHAM(t *testing.T) {
+	// LZHAMは名前の解析、書き出し、展開のいずれも同じエラーになる
+	_, err := ParseCompressionType("lzham")
+	if err != ErrUnsupportedCompressionType {
+		t.Fatalf("ParseCompressionType: expected ErrUnsupportedCompressionType, got %v", err)
+	}
+	ct, err := ParseCompressionType("LZ4HC")
+	if err != nil || ct != CompressionTypeLZ4HC {
+		t.Fatalf("ParseCompressionType: unexpected %v, %v", ct, err)
+	}
+
+	for _, opts := range []BundleWriterOptions{
+		{CompressionType: CompressionTypeLZHAM},
+		{BlockInfoCompressionType: CompressionTypeLZHAM},
+	} {
+		err = WriteBundle(&bytes.Buffer{}, testBundleNodes(), &opts)
+		if err != ErrUnsupportedCompressionType {
+			t.Fatalf("WriteBundle: expected ErrUnsupportedCompressionType, got %v", err)
+		}
+	}
+
+	_, err = decompressBlock([]byte{0}, 1, CompressionTypeLZHAM)
+	if err != ErrUnsupportedCompressionType {
+		t.Fatalf("decompressBlock: expected ErrUnsupportedCompressionType, got %v", err)
+	}
+}
+
+func TestRecompress(t *testing.T) {
+	nodes := testBundleNodes()
+	guid := []byte("fedcba9876543210")
+
+	var src bytes.Buffer
+	err := WriteBundle(&src, nodes, &BundleWriterOptions{
+		PlayerVersion:            "5.6.7f1",
+		GUID:                     guid,
+		CompressionType:          CompressionTypeLZMA,
+		BlockInfoCompressionType: CompressionTypeLZMA,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := OpenBundle(bytes.NewReader(src.Bytes()), int64(src.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dst bytes.Buffer
+	err = b.Recompress(&dst, &BundleWriterOptions{CompressionType: CompressionTypeLZ4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rb, err := OpenBundle(bytes.NewReader(dst.Bytes()), int64(dst.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rb.GUID, guid) || rb.PlayerVersion != "5.6.7f1" {
+		t.Fatal("GUIDまたはPlayerVersionが維持されていません")
+	}
+	for _, block := range rb.Blocks {
+		if compressionType(block.BFlags&blockCompressionMask) == CompressionTypeLZMA {
+			t.Fatal("LZMAブロックが残っています")
+		}
+	}
+	for i, node := range rb.Nodes {
+		data, err := rb.ReadNode(node)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, nodes[i].Data) {
+			t.Fatalf("%s の内容が一致しません", node.Name)
+		}
+	}
+}
+
+// testUnityFSData 手で組み立てたUnityFSフィクスチャのノードの内容 (65バイト)
+var testUnityFSData = append(bytes.Repeat([]byte("ab"), 16), []byte("0123456789abcdefghijklmnopqrstuvw")...)
+
+// testUnityFSFixture UnityFS形式のBundleをUnityのレイアウトどおりに組み立てる
+// データは32バイトごとのブロックに分け、lz4の場合は最初のブロックを手で組み立てたLZ4ブロックにする
+// ノードは CAB-fixture (0-40) と CAB-fixture.resS (40-65)
+func testUnityFSFixture(formatVersion int32, playerVersion string, flags uint32, headerPadding, lz4 bool) []byte {
+	type block struct {
+		uncompressedSize, compressedSize uint32
+		flags                            uint16
+		data                             []byte
+	}
+	blocks := []block{
+		{32, 32, 0, testUnityFSData[:32]},
+		{32, 32, 0, testUnityFSData[32:64]},
+		{1, 1, 0, testUnityFSData[64:]},
+	}
+	if lz4 {
+		// リテラル "ab"、オフセット2で長さ25のマッチ、末尾5バイトのリテラル
+		compressed := []byte{0x2f, 'a', 'b', 0x02, 0x00, 0x06, 0x50, 'b', 'a', 'b', 'a', 'b'}
+		blocks[0] = block{32, uint32(len(compressed)), uint16(CompressionTypeLZ4), compressed}
+	}
+
+	blockInfo := &fixtureBuffer{order: binary.BigEndian}
+	blockInfo.put([]byte("fixture-guid-016"), int32(len(blocks)))
+	for _, b := range blocks {
+		blockInfo.put(b.uncompressedSize, b.compressedSize, b.flags)
+	}
+	blockInfo.put(int32(2))
+	blockInfo.put(int64(0), int64(40), int32(4), "CAB-fixture")
+	blockInfo.put(int64(40), int64(25), int32(0), "CAB-fixture.resS")
+
+	buf := &fixtureBuffer{order: binary.BigEndian}
+	buf.put("UnityFS", formatVersion, "5.x.x", playerVersion)
+	sizeAt := buf.Len()
+	buf.put(int64(0), uint32(blockInfo.Len()), uint32(blockInfo.Len()), flags)
+	align16 := func() {
+		for buf.Len()%16 != 0 {
+			buf.WriteByte(0)
+		}
+	}
+	if headerPadding {
+		align16()
+	}
+	if flags&BundleFlagBlocksInfoAtTheEnd == 0 {
+		buf.Write(blockInfo.Bytes())
+	}
+	if flags&BundleFlagBlockInfoNeedPaddingAtStart != 0 {
+		align16()
+	}
+	for _, b := range blocks {
+		buf.Write(b.data)
+	}
+	if flags&BundleFlagBlocksInfoAtTheEnd != 0 {
+		buf.Write(blockInfo.Bytes())
+	}
+
+	data := buf.Bytes()
+	binary.BigEndian.PutUint64(data[sizeAt:], uint64(len(data)))
+	return data
+}
+
+func TestOpenBundleFSFixtures(t *testing.T) {
+	cases := []struct {
+		name          string
+		formatVersion int32
+		playerVersion string
+		flags         uint32
+		headerPadding bool
+		lz4           bool
+		// sameAsWriter WriteBundleが同じバイト列を書き出すかどうか
+		sameAsWriter bool
+	}{
+		{"format 6", 6, "5.6.7f1", 0x40, false, false, true},
+		{"format 6 lz4", 6, "5.6.7f1", 0x40, false, true, false},
+		{"block info at end", 6, "2017.4.40f1", 0x40 | 0x80, false, true, false},
+		{"block info at end (writer)", 6, "2017.4.40f1", 0x40 | 0x80, false, false, true},
+		{"format 7 alignment", 7, "2020.3.1f1", 0x40, true, true, false},
+		{"format 7 alignment (writer)", 7, "2020.3.1f1", 0x40, true, false, true},
+		{"padding at start", 8, "2022.3.1f1", 0x40 | 0x200, true, true, false},
+		{"padding at start (writer)", 8, "2022.3.1f1", 0x40 | 0x200, true, false, true},
+		{"padding at start, block info at end", 8, "2022.3.1f1", 0x40 | 0x80 | 0x200, true, true, false},
+		{"format 6 2019.4 aligned", 6, "2019.4.16f1", 0x40, true, true, false},
+		{"format 6 2019.4 aligned (writer)", 6, "2019.4.16f1", 0x40, true, false, true},
+		{"format 6 2019.4 unaligned", 6, "2019.4.0f1", 0x40, false, true, false},
+		{"without combined flag", 6, "5.6.7f1", 0, false, true, false},
+	}
+
+	for _, c := range cases {
+		data := testUnityFSFixture(c.formatVersion, c.playerVersion, c.flags, c.headerPadding, c.lz4)
+		b, err := OpenBundle(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if len(b.Blocks) != 3 || b.Blocks[0].BuSize != 32 || (c.lz4 && b.Blocks[0].BcSize != 12) {
+			t.Fatalf("%s: unexpected blocks: %+v", c.name, b.Blocks)
+		}
+		if len(b.Nodes) != 2 || b.Nodes[0].Name != "CAB-fixture" || b.Nodes[1].Name != "CAB-fixture.resS" {
+			t.Fatalf("%s: unexpected nodes: %+v", c.name, b.Nodes)
+		}
+		for i, want := range [][]byte{testUnityFSData[:40], testUnityFSData[40:]} {
+			got, err := b.ReadNode(b.Nodes[i])
+			if err != nil {
+				t.Fatalf("%s: ReadNode: %v", c.name, err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%s: unexpected node data: %q", c.name, got)
+			}
+		}
+
+		if !c.sameAsWriter {
+			continue
+		}
+		var buf bytes.Buffer
+		err = WriteBundle(&buf, []BundleNodeData{
+			{Name: "CAB-fixture", Status: 4, Data: testUnityFSData[:40]},
+			{Name: "CAB-fixture.resS", Data: testUnityFSData[40:]},
+		}, &BundleWriterOptions{
+			FormatVersion: c.formatVersion,
+			PlayerVersion: c.playerVersion,
+			GUID:          []byte("fixture-guid-016"),
+			BlockSize:     32,
+			Flags:         c.flags,
+		})
+		if err != nil {
+			t.Fatalf("%s: WriteBundle: %v", c.name, err)
+		}
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Fatalf("%s: WriteBundle output differs from the fixture:\n got %x\nwant %x", c.name, buf.Bytes(), data)
+		}
+	}
+}
+
+func TestOpenBundleCorruptSizes(t *testing.T) {
+	// ヘッダのブロック情報のサイズがファイルを超える
+	data := testUnityFSFixture(6, "5.6.7f1", 0x40, false, true)
+	ciBlockSizeAt := len("UnityFS\x00") + 4 + len("5.x.x\x00") + len("5.6.7f1\x00") + 8
+	binary.BigEndian.PutUint32(data[ciBlockSizeAt:], 0xfffffff0)
+	_, err := OpenBundle(bytes.NewReader(data), int64(len(data)))
+	if err != ErrInvalidNodeRange {
+		t.Fatalf("block info: expected ErrInvalidNodeRange, got %v", err)
+	}
+
+	// ブロックの圧縮後のサイズ、ノードのサイズがファイルやデータを超える
+	data = testUnityFSFixture(6, "5.6.7f1", 0x40, false, true)
+	b, err := OpenBundle(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.blocks[2].BcSize = -1
+	_, err = b.ReadNode(b.Nodes[1])
+	if err != ErrInvalidNodeRange {
+		t.Fatalf("block: expected ErrInvalidNodeRange, got %v", err)
+	}
+	for _, node := range []FSNode{
+		{Offset: 0, Size: 1 << 40},
+		{Offset: 64, Size: 2},
+		{Offset: -1, Size: 1},
+	} {
+		_, err = b.ReadNode(node)
+		if err != ErrInvalidNodeRange {
+			t.Fatalf("node %+v: expected ErrInvalidNodeRange, got %v", node, err)
+		}
+	}
+}
+
+// testLegacyBundle UnityRaw / UnityWeb / UnityArchive形式 (format 3) のBundleを組み立てる
+// UnityWebの場合はノード一覧とデータを含むストリームをLZMA-Alone形式で圧縮する
+func testLegacyBundle(t *testing.T, signature string, nodes []BundleNodeData) []byte {
+	// ストリーム: ノード数、ノード一覧 (名前、オフセット、サイズ)、各ノードのデータ
+	listSize := 4
+	for _, node := range nodes {
+		listSize += len(node.Name) + 1 + 8
+	}
+	var stream bytes.Buffer
+	binary.Write(&stream, binary.BigEndian, int32(len(nodes)))
+	offset := listSize
+	for _, node := range nodes {
+		stream.WriteString(node.Name)
+		stream.WriteByte(0)
+		binary.Write(&stream, binary.BigEndian, uint32(offset))
+		binary.Write(&stream, binary.BigEndian, uint32(len(node.Data)))
+		offset += len(node.Data)
+	}
+	for _, node := range nodes {
+		stream.Write(node.Data)
+	}
+
+	data := stream.Bytes()
+	if signature == SignatureUnityWeb {
+		var compressed bytes.Buffer
+		w, err := lzma.NewWriter(&compressed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = w.Write(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = w.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = compressed.Bytes()
+	}
+
+	header := func(headerSize int) []byte {
+		var buf bytes.Buffer
+		buf.WriteString(signature + "\x00")
+		binary.Write(&buf, binary.BigEndian, int32(3))
+		buf.WriteString("3.x.x\x00")
+		buf.WriteString("4.7.2f1\x00")
+		for _, v := range []uint32{
+			uint32(len(data)),              // minimumStreamedBytes
+			uint32(headerSize),             // headerSize
+			1,                              // numLevelsBeforeStreaming
+			1,                              // numLevels
+			uint32(len(data)),              // compressedEnd
+			uint32(stream.Len()),           // uncompressedEnd
+			uint32(headerSize + len(data)), // completeFileSize
+			uint32(listSize),               // fileInfoHeaderSize
+		} {
+			binary.Write(&buf, binary.BigEndian, v)
+		}
+		return buf.Bytes()
+	}
+	headerSize := int(alignOffset(int64(len(header(0))), 4))
+
+	b := make([]byte, headerSize, headerSize+len(data))
+	copy(b, header(headerSize))
+	return append(b, data...)
+}
+
+func TestOpenBundleLegacy(t *testing.T) {
+	nodes := []BundleNodeData{
+		{Name: "CAB-legacy", Data: bytes.Repeat([]byte("legacy-serialized-file "), 500)},
+		{Name: "CAB-legacy.resS", Data: []byte("0123456789")},
+		{Name: "CAB-empty", Data: []byte{}},
+	}
+
+	for _, signature := range []string{SignatureUnityRaw, SignatureUnityWeb, SignatureUnityArchive} {
+		data := testLegacyBundle(t, signature, nodes)
+		b, err := OpenBundle(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("%s: %v", signature, err)
+		}
+		if b.Signature != signature || b.FormatVersion != 3 || b.PlayerVersion != "4.7.2f1" {
+			t.Fatalf("%s: ヘッダが一致しません: %+v", signature, b)
+		}
+		if len(b.Nodes) != len(nodes) {
+			t.Fatalf("%s: ノード数が一致しません: %d", signature, len(b.Nodes))
+		}
+		for i, node := range b.Nodes {
+			if node.Name != nodes[i].Name || node.Size != int64(len(nodes[i].Data)) {
+				t.Fatalf("%s: ノード情報が一致しません: %+v", signature, node)
+			}
+			data, err := b.ReadNode(node)
+			if err != nil {
+				t.Fatalf("%s: ReadNode: %v", signature, err)
+			}
+			if !bytes.Equal(data, nodes[i].Data) {
+				t.Fatalf("%s: %s の内容が一致しません", signature, node.Name)
+			}
+		}
+	}
+
+	// ノード一覧がストリームの範囲外を指す場合は読み込み時に失敗する
+	nodes[0].Data = nodes[0].Data[:10]
+	data := testLegacyBundle(t, SignatureUnityArchive, nodes)
+	truncated := data[:len(data)-len(nodes[1].Data)-5]
+	b, err := OpenBundle(bytes.NewReader(truncated), int64(len(truncated)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.ReadNode(b.Nodes[1])
+	if err != ErrInvalidNodeRange {
+		t.Fatalf("expected ErrInvalidNodeRange, got %v", err)
+	}
+}
@@ -0,0 +1,428 @@
+package unity
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultBundleFormatVersion 書き出し時のUnityFSフォーマットバージョン
+	DefaultBundleFormatVersion = 6
+
+	// DefaultBundleEngineVersion 書き出し時のエンジンバージョン
+	DefaultBundleEngineVersion = "5.x.x"
+
+	// DefaultBundleBlockSize 書き出し時のブロックサイズ (Unityのチャンク単位の圧縮と同じ128KiB)
+	DefaultBundleBlockSize = 128 * 1024
+)
+
+// maxBundleBlockSize 1ブロックの最大サイズ。ブロック情報のサイズはint32のため、これを超えるデータは複数のブロックに分ける
+var maxBundleBlockSize int64 = math.MaxInt32
+
+// BundleNodeData 書き出すノードの名前と内容
+type BundleNodeData struct {
+	Name   string
+	Status int32
+	Data   []byte
+}
+
+// BundleWriterOptions UnityFS書き出しオプション
+type BundleWriterOptions struct {
+	FormatVersion int32
+	EngineVersion string
+	PlayerVersion string
+	GUID          []byte
+
+	// BlockSize 展開後ブロックの最大サイズ。0の場合はDefaultBundleBlockSize (LZMAは全体で1ブロック)
+	// いずれの場合も2GiB (math.MaxInt32) を超えるブロックは分割する
+	BlockSize int
+
+	// CompressionType データブロックの圧縮形式
+	CompressionType compressionType
+
+	// BlockInfoCompressionType ブロック情報の圧縮形式
+	BlockInfoCompressionType compressionType
+
+	// Flags BundleFlag*の組み合わせ。圧縮形式のビットは無視される
+	Flags uint32
+}
+
+// WriteBundle ノード一覧からUnityFS形式のBundleを書き出す
+func WriteBundle(w io.Writer, nodes []BundleNodeData, opts *BundleWriterOptions) error {
+	fsNodes := []FSNode{}
+	readers := []*io.SectionReader{}
+	for _, node := range nodes {
+		fsNodes = append(fsNodes, FSNode{
+			Size:   int64(len(node.Data)),
+			Status: node.Status,
+			Name:   node.Name,
+		})
+		readers = append(readers, io.NewSectionReader(bytes.NewReader(node.Data), 0, int64(len(node.Data))))
+	}
+	return writeBundle(w, fsNodes, readers, opts)
+}
+
+// writeBundle ノードの内容をブロック単位で読み込みながら圧縮してUnityFS形式のBundleを書き出す
+// 展開後のデータ全体はメモリに持たず、圧縮で小さくならないブロックは書き出し時に読み直す
+// ブロック情報をデータより前に書くため、圧縮したブロックは全て書き出すまでメモリに持つ (圧縮後のサイズの合計が上限)
+func writeBundle(w io.Writer, nodes []FSNode, readers []*io.SectionReader, opts *BundleWriterOptions) error {
+	if opts == nil {
+		opts = &BundleWriterOptions{}
+	}
+
+	formatVersion := opts.FormatVersion
+	if formatVersion == 0 {
+		formatVersion = DefaultBundleFormatVersion
+	}
+
+	engineVersion := opts.EngineVersion
+	if engineVersion == "" {
+		engineVersion = DefaultBundleEngineVersion
+	}
+
+	// 対応していない圧縮形式はノードを読み込む前に弾く
+	if !opts.CompressionType.isSupported() || !opts.BlockInfoCompressionType.isSupported() {
+		return ErrUnsupportedCompressionType
+	}
+
+	guid := make([]byte, 16)
+	if opts.GUID != nil {
+		if len(opts.GUID) != 16 {
+			return ErrInvalidGUID
+		}
+		copy(guid, opts.GUID)
+	}
+
+	fsNodes := make([]FSNode, len(nodes))
+	data := &concatReaderAt{}
+	for i, node := range nodes {
+		fsNodes[i] = node
+		fsNodes[i].Offset = data.size
+		data.readers = append(data.readers, readers[i])
+		data.offsets = append(data.offsets, data.size)
+		data.size += readers[i].Size()
+	}
+
+	blockSize := int64(opts.BlockSize)
+	if blockSize <= 0 {
+		blockSize = DefaultBundleBlockSize
+		if opts.CompressionType == CompressionTypeLZMA && data.size > 0 {
+			blockSize = data.size
+		}
+	}
+	if blockSize > maxBundleBlockSize {
+		blockSize = maxBundleBlockSize
+	}
+
+	blocks := []FSBlock{}
+	blockData := []io.Reader{}
+	for start := int64(0); start < data.size; start += blockSize {
+		end := start + blockSize
+		if end > data.size {
+			end = data.size
+		}
+		size := end - start
+
+		ct := opts.CompressionType
+		var compData []byte
+		switch ct {
+		case CompressionTypeNone:
+		case CompressionTypeLZMA:
+			// LZMAは1ブロックが大きいため展開後のデータを読み込みながら圧縮する
+			var err error
+			compData, err = compressLZMA(io.NewSectionReader(data, start, size), size)
+			if err != nil {
+				return err
+			}
+		default:
+			chunk, err := readAt(data, start, int(size))
+			if err != nil {
+				return err
+			}
+			compData, err = compressBlock(chunk, ct)
+			if err != nil {
+				return err
+			}
+		}
+
+		var block io.Reader = bytes.NewReader(compData)
+		compSize := int64(len(compData))
+		// 圧縮で小さくならないブロックは無圧縮で格納する
+		if ct == CompressionTypeNone || compSize >= size {
+			ct = CompressionTypeNone
+			block = io.NewSectionReader(data, start, size)
+			compSize = size
+		}
+
+		blocks = append(blocks, FSBlock{
+			BcSize: int32(compSize),
+			BuSize: int32(size),
+			BFlags: int16(ct),
+		})
+		blockData = append(blockData, block)
+	}
+
+	blockInfoWriter := NewDataWriter()
+	err := writeBundleBlockInfo(blockInfoWriter, guid, blocks, fsNodes)
+	if err != nil {
+		return err
+	}
+	blockInfo := blockInfoWriter.Bytes()
+
+	compBlockInfo, err := compressBlock(blockInfo, opts.BlockInfoCompressionType)
+	if err != nil {
+		return err
+	}
+
+	// FormatVersion 6の2019.4もヘッダの後を16バイト境界に揃える (読み込み時は境界までの0を読み飛ばす)
+	alignHeader := formatVersion >= 7 || strings.HasPrefix(opts.PlayerVersion, "2019.4.")
+
+	flags := opts.Flags&^blockCompressionMask | uint32(opts.BlockInfoCompressionType) | BundleFlagBlocksAndDirectoryInfoCombined
+
+	headerWriter := NewDataWriter()
+	err = writeBundleHeader(headerWriter, formatVersion, engineVersion, opts.PlayerVersion, 0, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	// ヘッダ長からファイル全体のサイズを求める
+	size := int64(headerWriter.Len())
+	if alignHeader {
+		size = alignOffset(size, 16)
+	}
+	if flags&BundleFlagBlocksInfoAtTheEnd == 0 {
+		size += int64(len(compBlockInfo))
+	}
+	if flags&BundleFlagBlockInfoNeedPaddingAtStart != 0 {
+		size = alignOffset(size, 16)
+	}
+	for _, block := range blocks {
+		size += int64(block.BcSize)
+	}
+	if flags&BundleFlagBlocksInfoAtTheEnd != 0 {
+		size += int64(len(compBlockInfo))
+	}
+
+	dataWriter := NewDataWriter()
+	err = writeBundleHeader(dataWriter, formatVersion, engineVersion, opts.PlayerVersion, size, uint32(len(compBlockInfo)), uint32(len(blockInfo)), flags)
+	if err != nil {
+		return err
+	}
+
+	if alignHeader {
+		err = dataWriter.AlignTo(16)
+		if err != nil {
+			return err
+		}
+	}
+
+	if flags&BundleFlagBlocksInfoAtTheEnd == 0 {
+		err = dataWriter.WriteBytes(compBlockInfo)
+		if err != nil {
+			return err
+		}
+	}
+
+	if flags&BundleFlagBlockInfoNeedPaddingAtStart != 0 {
+		err = dataWriter.AlignTo(16)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = w.Write(dataWriter.Bytes())
+	if err != nil {
+		return err
+	}
+
+	for _, block := range blockData {
+		_, err = io.Copy(w, block)
+		if err != nil {
+			return err
+		}
+	}
+
+	if flags&BundleFlagBlocksInfoAtTheEnd != 0 {
+		_, err = w.Write(compBlockInfo)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeBundleHeader UnityFSのヘッダを書き込む
+func writeBundleHeader(dataWriter *DataWriter, formatVersion int32, engineVersion, playerVersion string, fileSize int64, ciBlockSize, uiBlockSize, flags uint32) error {
+	err := dataWriter.WriteStringNull(SignatureUnityFS)
+	if err != nil {
+		return err
+	}
+
+	err = dataWriter.WriteInt(formatVersion, false)
+	if err != nil {
+		return err
+	}
+
+	err = dataWriter.WriteStringNull(engineVersion)
+	if err != nil {
+		return err
+	}
+
+	err = dataWriter.WriteStringNull(playerVersion)
+	if err != nil {
+		return err
+	}
+
+	err = dataWriter.WriteLong(fileSize, false)
+	if err != nil {
+		return err
+	}
+
+	err = dataWriter.WriteUint(ciBlockSize, false)
+	if err != nil {
+		return err
+	}
+
+	err = dataWriter.WriteUint(uiBlockSize, false)
+	if err != nil {
+		return err
+	}
+
+	return dataWriter.WriteUint(flags, false)
+}
+
+// writeBundleBlockInfo ブロック情報とノード一覧を書き込む
+func writeBundleBlockInfo(dataWriter *DataWriter, guid []byte, blocks []FSBlock, nodes []FSNode) error {
+	err := dataWriter.WriteBytes(guid)
+	if err != nil {
+		return err
+	}
+
+	err = dataWriter.WriteInt(int32(len(blocks)), false)
+	if err != nil {
+		return err
+	}
+
+	for _, block := range blocks {
+		err = dataWriter.WriteInt(block.BuSize, false)
+		if err != nil {
+			return err
+		}
+
+		err = dataWriter.WriteInt(block.BcSize, false)
+		if err != nil {
+			return err
+		}
+
+		err = dataWriter.WriteShort(block.BFlags, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = dataWriter.WriteInt(int32(len(nodes)), false)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		err = dataWriter.WriteLong(node.Offset, false)
+		if err != nil {
+			return err
+		}
+
+		err = dataWriter.WriteLong(node.Size, false)
+		if err != nil {
+			return err
+		}
+
+		err = dataWriter.WriteInt(node.Status, false)
+		if err != nil {
+			return err
+		}
+
+		err = dataWriter.WriteStringNull(node.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Recompress Bundleを別の圧縮形式で書き出す。ノードの内容とGUIDは維持する
+// optsで指定されていない項目は元のBundleの値を引き継ぐ
+func (b *Bundle) Recompress(w io.Writer, opts *BundleWriterOptions) error {
+	newOpts := BundleWriterOptions{}
+	if opts != nil {
+		newOpts = *opts
+	}
+
+	if newOpts.FormatVersion == 0 && b.Signature == SignatureUnityFS {
+		newOpts.FormatVersion = b.FormatVersion
+	}
+	if newOpts.EngineVersion == "" && b.Signature == SignatureUnityFS {
+		newOpts.EngineVersion = b.EngineVersion
+	}
+	if newOpts.PlayerVersion == "" {
+		newOpts.PlayerVersion = b.PlayerVersion
+	}
+	if newOpts.GUID == nil && len(b.GUID) == 16 {
+		newOpts.GUID = b.GUID
+	}
+	if newOpts.Flags == 0 && b.Signature == SignatureUnityFS {
+		newOpts.Flags = b.Flags &^ blockCompressionMask
+	}
+
+	readers := []*io.SectionReader{}
+	for _, node := range b.Nodes {
+		if node.Offset < 0 || node.Size < 0 {
+			return ErrInvalidNodeRange
+		}
+		readers = append(readers, b.NodeReader(node))
+	}
+
+	return writeBundle(w, b.Nodes, readers, &newOpts)
+}
+
+// concatReaderAt 複数のノードの内容を連結した1つのio.ReaderAt
+type concatReaderAt struct {
+	readers []*io.SectionReader
+	offsets []int64
+	size    int64
+}
+
+// ReadAt implements the io.ReaderAt interface.
+func (c *concatReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidNodeRange
+	}
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		i := sort.Search(len(c.readers), func(i int) bool {
+			return c.offsets[i]+c.readers[i].Size() > pos
+		})
+		if i == len(c.readers) {
+			return n, io.EOF
+		}
+
+		read, err := c.readers[i].ReadAt(p[n:], pos-c.offsets[i])
+		n += read
+		if err == io.EOF && read > 0 {
+			continue
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = ErrInvalidNodeRange
+			}
+			return n, err
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+
+	unity "github.com/PyYoshi/UnityAssets"
+)
+
+var (
+	basePath   string
+	outputPath string
+)
+
+func init() {
+	flag.StringVar(&basePath, "base", "", "Existing type tree database to extend (optional)")
+	flag.StringVar(&outputPath, "output", "", "Output type tree database path")
+}
+
+// addFile BundleまたはシリアライズファイルのType treeをデータベースに登録する
+func addFile(db *unity.TypeTreeDatabase, path string) (int, error) {
+	total := 0
+	err := unity.WalkSerializedFiles(path, func(name string, f *unity.SerializedFile, err error) error {
+		if err != nil {
+			log.Printf("%s: %s: skipped: %v", path, name, err)
+			return nil
+		}
+
+		n, err := db.AddSerializedFile(f)
+		total += n
+		return err
+	})
+	return total, err
+}
+
+func main() {
+	flag.Parse()
+
+	if outputPath == "" || flag.NArg() == 0 {
+		log.Fatal("usage: build_typetree_db -output types.db [-base old.db] bundle_or_serialized_file...")
+		return
+	}
+
+	db := unity.NewTypeTreeDatabase()
+	if basePath != "" {
+		var err error
+		db, err = unity.LoadTypeTreeDatabase(basePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	for _, path := range flag.Args() {
+		n, err := addFile(db, path)
+		if err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+		log.Printf("%s: %d types", path, n)
+	}
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := bufio.NewWriter(out)
+	_, err = db.WriteTo(w)
+	if err != nil {
+		out.Close()
+		log.Fatal(err)
+	}
+
+	err = w.Flush()
+	if err != nil {
+		out.Close()
+		log.Fatal(err)
+	}
+
+	err = out.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%d types written to %s", db.Len(), outputPath)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	unity "github.com/PyYoshi/UnityAssets"
+)
+
+var (
+	oldPath    string
+	newPath    string
+	oldVersion string
+	newVersion string
+	jsonOutput bool
+)
+
+func init() {
+	flag.StringVar(&oldPath, "old", "", "Old bundle, serialized file or type tree database")
+	flag.StringVar(&newPath, "new", "", "New bundle, serialized file or type tree database")
+	flag.StringVar(&oldVersion, "old-version", "", "Unity version to take from the old type tree database (default: all)")
+	flag.StringVar(&newVersion, "new-version", "", "Unity version to take from the new type tree database (default: all)")
+	flag.BoolVar(&jsonOutput, "json", false, "Output JSON instead of text")
+}
+
+// loadClassTypes type treeデータベース、Bundle、シリアライズファイルの順に開いて型を集める
+// type treeデータベースにはScriptIDが無いため、スクリプトの型はClassIDのみで対応付けられる
+func loadClassTypes(path, unityVersion string) ([]unity.ClassType, error) {
+	classTypes := []unity.ClassType{}
+	db, err := unity.LoadTypeTreeDatabase(path)
+	if err == nil {
+		for _, typeTree := range db.TypeTrees(unityVersion) {
+			classTypes = append(classTypes, unity.ClassType{TypeTree: typeTree})
+		}
+		return classTypes, nil
+	}
+	if err != unity.ErrInvalidTypeTreeDatabase {
+		return nil, err
+	}
+
+	err = unity.WalkSerializedFiles(path, func(name string, f *unity.SerializedFile, err error) error {
+		if err != nil {
+			log.Printf("%s: %s: skipped: %v", path, name, err)
+			return nil
+		}
+		classTypes = append(classTypes, f.ClassTypes()...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return classTypes, nil
+}
+
+func main() {
+	flag.Parse()
+
+	if oldPath == "" || newPath == "" {
+		log.Fatal("-old and -new are required")
+		return
+	}
+
+	oldTypes, err := loadClassTypes(oldPath, oldVersion)
+	if err != nil {
+		log.Fatalf("%s: %v", oldPath, err)
+	}
+
+	newTypes, err := loadClassTypes(newPath, newVersion)
+	if err != nil {
+		log.Fatalf("%s: %v", newPath, err)
+	}
+
+	diffs := unity.DiffTypeTrees(oldTypes, newTypes)
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(diffs)
+	} else {
+		err = unity.WriteTypeTreeDiffText(os.Stdout, diffs)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	unity "github.com/PyYoshi/UnityAssets"
+)
+
+var (
+	typeTreeDatabasePath string
+	verifyHashes         bool
+)
+
+func init() {
+	flag.StringVar(&typeTreeDatabasePath, "typedb", "", "Type tree database for files without type trees (optional)")
+	flag.BoolVar(&verifyHashes, "verify-hashes", false, "Report type hashes that do not match the type trees")
+}
+
+// dumpSerializedFile 型ハッシュを確認した上でダンプする
+func dumpSerializedFile(w *bufio.Writer, name string, f *unity.SerializedFile) error {
+	if verifyHashes {
+		for _, mismatch := range f.VerifyTypeHashes() {
+			log.Printf("%s: %s", name, mismatch)
+		}
+	}
+	return f.Dump(w)
+}
+
+// dumpFile Bundleの場合は各シリアライズファイルのノードを、それ以外はファイル自体をダンプする
+func dumpFile(w *bufio.Writer, path string) error {
+	return unity.WalkSerializedFiles(path, func(name string, f *unity.SerializedFile, err error) error {
+		if err != nil {
+			log.Printf("%s: %s: skipped: %v", path, name, err)
+			return nil
+		}
+		if name == "" {
+			return dumpSerializedFile(w, path, f)
+		}
+
+		fmt.Fprintf(w, "=== %s ===\n", name)
+		return dumpSerializedFile(w, path+": "+name, f)
+	})
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		log.Fatal("usage: dump_serialized [-typedb types.db] bundle_or_serialized_file...")
+		return
+	}
+
+	if typeTreeDatabasePath != "" {
+		db, err := unity.LoadTypeTreeDatabase(typeTreeDatabasePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		unity.DefaultTypeTreeDatabase = db
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	for _, path := range flag.Args() {
+		err := dumpFile(w, path)
+		if err != nil {
+			w.Flush()
+			log.Fatalf("%s: %v", path, err)
+		}
+	}
+
+	err := w.Flush()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
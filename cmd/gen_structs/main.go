@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+
+	unity "github.com/PyYoshi/UnityAssets"
+)
+
+var (
+	inputPath            string
+	typeTreeDatabasePath string
+	unityVersion         string
+	packageName          string
+	outputPath           string
+)
+
+func init() {
+	flag.StringVar(&inputPath, "input", "", "Bundle or serialized file with type trees")
+	flag.StringVar(&typeTreeDatabasePath, "typedb", "", "Type tree database (used instead of -input)")
+	flag.StringVar(&unityVersion, "version", "", "Unity version to take from the type tree database (default: all)")
+	flag.StringVar(&packageName, "package", "unitytypes", "Package name of the generated file")
+	flag.StringVar(&outputPath, "output", "", "Output path (default: stdout)")
+}
+
+// loadTypeTrees 入力ファイルまたはtype treeデータベースからルートのtype treeを集める
+func loadTypeTrees() ([]*unity.TypeTree, error) {
+	if typeTreeDatabasePath != "" {
+		db, err := unity.LoadTypeTreeDatabase(typeTreeDatabasePath)
+		if err != nil {
+			return nil, err
+		}
+		return db.TypeTrees(unityVersion), nil
+	}
+
+	typeTrees := []*unity.TypeTree{}
+	err := unity.WalkSerializedFiles(inputPath, func(name string, f *unity.SerializedFile, err error) error {
+		if err != nil {
+			log.Printf("%s: %s: skipped: %v", inputPath, name, err)
+			return nil
+		}
+		if f.TypeMetadata == nil || !f.TypeMetadata.HasTypeTrees {
+			return nil
+		}
+		for i := range f.TypeMetadata.TypeTrees {
+			typeTrees = append(typeTrees, &f.TypeMetadata.TypeTrees[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return typeTrees, nil
+}
+
+func main() {
+	flag.Parse()
+
+	if inputPath == "" && typeTreeDatabasePath == "" {
+		log.Fatal("-input or -typedb is required")
+		return
+	}
+
+	typeTrees, err := loadTypeTrees()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	src, err := unity.GenerateStructs(packageName, typeTrees)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if outputPath == "" {
+		_, err = os.Stdout.Write(src)
+	} else {
+		err = ioutil.WriteFile(outputPath, src, 0644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
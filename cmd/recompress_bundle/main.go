@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+
+	unity "github.com/PyYoshi/UnityAssets"
+)
+
+var (
+	inputPath            string
+	outputPath           string
+	compression          string
+	blockInfoCompression string
+	blockSize            int
+)
+
+func init() {
+	flag.StringVar(&inputPath, "input", "", "Input bundle path")
+	flag.StringVar(&outputPath, "output", "", "Output bundle path")
+	flag.StringVar(&compression, "compression", "lz4hc", "Block compression: none, lzma, lz4, lz4hc")
+	flag.StringVar(&blockInfoCompression, "blockinfo-compression", "", "Block info compression: none, lzma, lz4, lz4hc (default: same as -compression)")
+	flag.IntVar(&blockSize, "block-size", 0, "Uncompressed block size in bytes (default: 128KiB, whole data for lzma)")
+}
+
+func main() {
+	flag.Parse()
+
+	if inputPath == "" || outputPath == "" {
+		log.Fatal("-input and -output are required")
+		return
+	}
+
+	ct, err := unity.ParseCompressionType(compression)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	bict := ct
+	if blockInfoCompression != "" {
+		bict, err = unity.ParseCompressionType(blockInfoCompression)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	in, err := os.Open(inputPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	b, err := unity.OpenBundle(in, stat.Size())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := bufio.NewWriter(out)
+	err = b.Recompress(w, &unity.BundleWriterOptions{
+		BlockSize:                blockSize,
+		CompressionType:          ct,
+		BlockInfoCompressionType: bict,
+	})
+	if err != nil {
+		out.Close()
+		log.Fatal(err)
+	}
+
+	err = w.Flush()
+	if err != nil {
+		out.Close()
+		log.Fatal(err)
+	}
+
+	err = out.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -2,7 +2,10 @@
 
 package unity
 
-import "strconv"
+import (
+	"strconv"
+	"strings"
+)
 
 type ClassID uint32
 
@@ -16,18 +19,30 @@ type VersionInfo struct {
 }
 
 // NewVersionInfo Unityバージョン情報を生成
+// "2020.3.10f1" のようにMajor / Minor / Patchが複数桁のものも扱い、パースできない場合はnilを返す
 func NewVersionInfo(version string) *VersionInfo {
-	major, err := strconv.Atoi(version[0:1])
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) != 3 {
+		return nil
+	}
+
+	major, err := strconv.Atoi(parts[0])
 	if err != nil {
 		return nil
 	}
 
-	minor, err := strconv.Atoi(version[2:3])
+	minor, err := strconv.Atoi(parts[1])
 	if err != nil {
 		return nil
 	}
 
-	patch, err := strconv.Atoi(version[4:5])
+	digits := strings.IndexFunc(parts[2], func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	if digits < 0 {
+		digits = len(parts[2])
+	}
+	patch, err := strconv.Atoi(parts[2][:digits])
 	if err != nil {
 		return nil
 	}
@@ -36,8 +51,60 @@ func NewVersionInfo(version string) *VersionInfo {
 	uVer.Major = major
 	uVer.Minor = minor
 	uVer.Patch = patch
-	uVer.Build = version[5:]
+	uVer.Build = parts[2][digits:]
 	uVer.raw = version
 
 	return uVer
 }
+
+// releaseTypeOrder Buildのリリース種別の順序 (alpha < beta < release candidate < final < patch)
+var releaseTypeOrder = map[string]int{"a": 1, "b": 2, "rc": 3, "f": 4, "p": 5}
+
+// Compare バージョンを比べ、vがoより古ければ負、新しければ正、同じなら0を返す
+// Major / Minor / Patch とBuildの番号は数値として比べる (2019.4.0f1 > 5.6.0f1、2020.3.10f1 > 2020.3.9f1)
+func (v *VersionInfo) Compare(o *VersionInfo) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d != 0 {
+			return d
+		}
+	}
+
+	a := splitVersionBuild(v.Build)
+	b := splitVersionBuild(o.Build)
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+
+		x, errX := strconv.Atoi(a[i])
+		y, errY := strconv.Atoi(b[i])
+		if errX == nil && errY == nil {
+			return x - y
+		}
+
+		rankX, okX := releaseTypeOrder[a[i]]
+		rankY, okY := releaseTypeOrder[b[i]]
+		if okX && okY {
+			return rankX - rankY
+		}
+		return strings.Compare(a[i], b[i])
+	}
+	return len(a) - len(b)
+}
+
+// splitVersionBuild Buildを英字と数字の並びに分ける ("f1c1" -> f, 1, c, 1)
+func splitVersionBuild(build string) []string {
+	parts := []string{}
+	start := 0
+	for i := 1; i <= len(build); i++ {
+		if i == len(build) || isDigit(build[i]) != isDigit(build[start]) {
+			parts = append(parts, build[start:i])
+			start = i
+		}
+	}
+	return parts
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
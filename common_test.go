@@ -7,4 +7,28 @@ func TestNewVersionInfo(t *testing.T) {
 	if !(v.Major == 5 && v.Minor == 3 && v.Patch == 3 && v.Build == "p3") {
 		t.Fatal("正しくバージョン情報がパースされていません")
 	}
+
+	v = NewVersionInfo("2020.3.10f1")
+	if !(v.Major == 2020 && v.Minor == 3 && v.Patch == 10 && v.Build == "f1") {
+		t.Fatal("正しくバージョン情報がパースされていません")
+	}
+
+	for _, s := range []string{"", "5.x.x", "2020.3", "abc"} {
+		if NewVersionInfo(s) != nil {
+			t.Fatalf("%q: パースできないバージョンはnilになるはずです", s)
+		}
+	}
+}
+
+func TestVersionInfoCompare(t *testing.T) {
+	// 古い順
+	versions := []string{"5.6.0f1", "5.6.0p3", "2019.4.0a12", "2019.4.0b1", "2019.4.0f1", "2019.4.0f1c1", "2020.3.9f1", "2020.3.10f1"}
+	for i := range versions {
+		for j := range versions {
+			c := NewVersionInfo(versions[i]).Compare(NewVersionInfo(versions[j]))
+			if (i < j && c >= 0) || (i == j && c != 0) || (i > j && c <= 0) {
+				t.Fatalf("%s と %s の比較結果が不正です: %d", versions[i], versions[j], c)
+			}
+		}
+	}
 }
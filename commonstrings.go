@@ -0,0 +1,141 @@
+package unity
+
+import "strings"
+
+// commonStringBuffer Unity組み込みの共通文字列テーブル
+// type treeの文字列オフセットの最上位ビットが立っている場合はこのテーブルを参照する
+const commonStringBuffer = "" +
+	"AABB\x00" +
+	"AnimationClip\x00" +
+	"AnimationCurve\x00" +
+	"AnimationState\x00" +
+	"Array\x00" +
+	"Base\x00" +
+	"BitField\x00" +
+	"bitset\x00" +
+	"bool\x00" +
+	"char\x00" +
+	"ColorRGBA\x00" +
+	"Component\x00" +
+	"data\x00" +
+	"deque\x00" +
+	"double\x00" +
+	"dynamic_array\x00" +
+	"FastPropertyName\x00" +
+	"first\x00" +
+	"float\x00" +
+	"Font\x00" +
+	"GameObject\x00" +
+	"Generic Mono\x00" +
+	"GradientNEW\x00" +
+	"GUID\x00" +
+	"GUIStyle\x00" +
+	"int\x00" +
+	"list\x00" +
+	"long long\x00" +
+	"map\x00" +
+	"Matrix4x4f\x00" +
+	"MdFour\x00" +
+	"MonoBehaviour\x00" +
+	"MonoScript\x00" +
+	"m_ByteSize\x00" +
+	"m_Curve\x00" +
+	"m_EditorClassIdentifier\x00" +
+	"m_EditorHideFlags\x00" +
+	"m_Enabled\x00" +
+	"m_ExtensionPtr\x00" +
+	"m_GameObject\x00" +
+	"m_Index\x00" +
+	"m_IsArray\x00" +
+	"m_IsStatic\x00" +
+	"m_MetaFlag\x00" +
+	"m_Name\x00" +
+	"m_ObjectHideFlags\x00" +
+	"m_PrefabInternal\x00" +
+	"m_PrefabParentObject\x00" +
+	"m_Script\x00" +
+	"m_StaticEditorFlags\x00" +
+	"m_Type\x00" +
+	"m_Version\x00" +
+	"Object\x00" +
+	"pair\x00" +
+	"PPtr<Component>\x00" +
+	"PPtr<GameObject>\x00" +
+	"PPtr<Material>\x00" +
+	"PPtr<MonoBehaviour>\x00" +
+	"PPtr<MonoScript>\x00" +
+	"PPtr<Object>\x00" +
+	"PPtr<Prefab>\x00" +
+	"PPtr<Sprite>\x00" +
+	"PPtr<TextAsset>\x00" +
+	"PPtr<Texture>\x00" +
+	"PPtr<Texture2D>\x00" +
+	"PPtr<Transform>\x00" +
+	"Prefab\x00" +
+	"Quaternionf\x00" +
+	"Rectf\x00" +
+	"RectInt\x00" +
+	"RectOffset\x00" +
+	"second\x00" +
+	"set\x00" +
+	"short\x00" +
+	"size\x00" +
+	"SInt16\x00" +
+	"SInt32\x00" +
+	"SInt64\x00" +
+	"SInt8\x00" +
+	"staticvector\x00" +
+	"string\x00" +
+	"TextAsset\x00" +
+	"TextMesh\x00" +
+	"Texture\x00" +
+	"Texture2D\x00" +
+	"Transform\x00" +
+	"TypelessData\x00" +
+	"UInt16\x00" +
+	"UInt32\x00" +
+	"UInt64\x00" +
+	"UInt8\x00" +
+	"unsigned int\x00" +
+	"unsigned long long\x00" +
+	"unsigned short\x00" +
+	"vector\x00" +
+	"Vector2f\x00" +
+	"Vector3f\x00" +
+	"Vector4f\x00" +
+	"m_ScriptingClassIdentifier\x00" +
+	"Gradient\x00" +
+	"Type*\x00" +
+	"int2_storage\x00" +
+	"int3_storage\x00" +
+	"BoundsInt\x00" +
+	"m_CorrespondingSourceObject\x00" +
+	"m_PrefabInstance\x00" +
+	"m_PrefabAsset\x00" +
+	"FileSize\x00" +
+	"Hash128\x00"
+
+// commonString 共通文字列テーブルのoffsetにある文字列を返す
+func commonString(offset uint32) (string, bool) {
+	if offset >= uint32(len(commonStringBuffer)) {
+		return "", false
+	}
+	s := commonStringBuffer[offset:]
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return s, true
+}
+
+// commonStringOffset 共通文字列テーブル上の文字列のオフセットを返す
+func commonStringOffset(s string) (uint32, bool) {
+	offset := 0
+	for offset < len(commonStringBuffer) {
+		end := offset + strings.IndexByte(commonStringBuffer[offset:], 0)
+		if commonStringBuffer[offset:end] == s {
+			return uint32(offset), true
+		}
+		offset = end + 1
+	}
+	return 0, false
+}
@@ -0,0 +1,132 @@
+package unity
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+
+	lz4 "github.com/jmoiron/golz4"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// blockCompressionMask ブロックフラグのうち圧縮形式を示すビット
+const blockCompressionMask = 0x3F
+
+// lzmaPropsSize LZMAプロパティヘッダのサイズ (lc/lp/pb + 辞書サイズ)
+const lzmaPropsSize = 5
+
+// isSupported 展開と圧縮に対応している圧縮形式かどうか
+func (c compressionType) isSupported() bool {
+	switch c {
+	case CompressionTypeNone, CompressionTypeLZMA, CompressionTypeLZ4, CompressionTypeLZ4HC:
+		return true
+	}
+	return false
+}
+
+// decompressBlock 圧縮形式に応じてブロックを展開
+func decompressBlock(data []byte, uncompressedSize int, ct compressionType) ([]byte, error) {
+	switch ct {
+	case CompressionTypeNone:
+		if len(data) != uncompressedSize {
+			return nil, ErrInvalidBlockSize
+		}
+		return data, nil
+	case CompressionTypeLZMA:
+		return decompressLZMA(data, uncompressedSize)
+	case CompressionTypeLZ4, CompressionTypeLZ4HC:
+		out := make([]byte, uncompressedSize)
+		err := lz4.Uncompress(data, out)
+		if err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+	return nil, ErrUnsupportedCompressionType
+}
+
+// decompressLZMA プロパティヘッダ付きのLZMAストリームを展開
+// UnityFSのブロックは展開後サイズを持たないため、LZMA-Alone形式のヘッダを組み立てて読み込む
+func decompressLZMA(data []byte, uncompressedSize int) ([]byte, error) {
+	if len(data) < lzmaPropsSize {
+		return nil, ErrInvalidBlockSize
+	}
+
+	header := make([]byte, lzmaPropsSize+8)
+	copy(header, data[:lzmaPropsSize])
+	binary.LittleEndian.PutUint64(header[lzmaPropsSize:], uint64(uncompressedSize))
+
+	r, err := lzma.NewReader(io.MultiReader(bytes.NewReader(header), bytes.NewReader(data[lzmaPropsSize:])))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, uncompressedSize)
+	_, err = io.ReadFull(r, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// decompressLZMAAlone 展開後サイズを含むLZMA-Alone形式のストリームを展開
+func decompressLZMAAlone(data []byte) ([]byte, error) {
+	r, err := lzma.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+// compressBlock 指定の圧縮形式でブロックを圧縮
+func compressBlock(data []byte, ct compressionType) ([]byte, error) {
+	switch ct {
+	case CompressionTypeNone:
+		return data, nil
+	case CompressionTypeLZMA:
+		return compressLZMA(bytes.NewReader(data), int64(len(data)))
+	case CompressionTypeLZ4, CompressionTypeLZ4HC:
+		out := make([]byte, lz4.CompressBound(data))
+		var n int
+		var err error
+		if ct == CompressionTypeLZ4HC {
+			n, err = lz4.CompressHC(data, out)
+		} else {
+			n, err = lz4.Compress(data, out)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return out[:n], nil
+	}
+	return nil, ErrUnsupportedCompressionType
+}
+
+// compressLZMA rからsizeバイトを読み込み、プロパティヘッダのみを持つUnity形式のLZMAストリームに圧縮
+func compressLZMA(r io.Reader, size int64) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := lzma.WriterConfig{
+		SizeInHeader: true,
+		Size:         size,
+	}.NewWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.CopyN(w, r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// LZMA-Alone形式のヘッダから展開後サイズ (8バイト) を取り除く
+	b := buf.Bytes()
+	out := make([]byte, 0, len(b)-8)
+	out = append(out, b[:lzmaPropsSize]...)
+	return append(out, b[lzmaPropsSize+8:]...), nil
+}
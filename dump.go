@@ -0,0 +1,168 @@
+package unity
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// dumpTypelessDataLimit ダンプに16進で出力するTypelessDataの最大バイト数
+const dumpTypelessDataLimit = 64
+
+// Dump binary2text形式でヘッダ、外部参照、type tree、全オブジェクトの値をテキストで書き出す
+// デコードできないオブジェクトはエラーを出力して次のオブジェクトに進む
+func (f *SerializedFile) Dump(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	f.dumpHeader(bw)
+	f.dumpExternals(bw)
+	f.dumpTypeTrees(bw)
+
+	for _, obj := range f.Objects {
+		f.dumpObject(bw, obj)
+	}
+
+	return bw.Flush()
+}
+
+func (f *SerializedFile) dumpHeader(w *bufio.Writer) {
+	endianness := "little"
+	if !f.IsLittleEndian {
+		endianness = "big"
+	}
+
+	fmt.Fprintf(w, "Format: %d\n", f.Format)
+	if f.TypeMetadata != nil {
+		fmt.Fprintf(w, "Unity version: %s\n", f.TypeMetadata.PlayerVersion)
+		fmt.Fprintf(w, "Target platform: %d\n", f.TypeMetadata.TargetPlatform)
+		fmt.Fprintf(w, "Has type trees: %v\n", f.TypeMetadata.HasTypeTrees)
+	}
+	fmt.Fprintf(w, "Endianness: %s\n", endianness)
+	fmt.Fprintf(w, "Metadata size: %d\n", f.MetadataSize)
+	fmt.Fprintf(w, "File size: %d\n", f.FileSize)
+	fmt.Fprintf(w, "Data offset: %d\n", f.DataOffset)
+	if f.UserInformation != "" {
+		fmt.Fprintf(w, "User information: %q\n", f.UserInformation)
+	}
+	fmt.Fprintln(w)
+}
+
+func (f *SerializedFile) dumpExternals(w *bufio.Writer) {
+	if len(f.Externals) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "External References")
+	for i, assetRef := range f.Externals {
+		// ファイルインデックス0は自身を指すため外部参照は1から
+		fmt.Fprintf(w, "path(%d): %q GUID: %s Type: %d\n", i+1, assetRef.FilePath, hex.EncodeToString(assetRef.GUID), assetRef.Type)
+	}
+	fmt.Fprintln(w)
+}
+
+func (f *SerializedFile) dumpTypeTrees(w *bufio.Writer) {
+	typeMetadata := f.TypeMetadata
+	if typeMetadata == nil || !typeMetadata.HasTypeTrees {
+		return
+	}
+
+	for i := range typeMetadata.TypeTrees {
+		typeTree := &typeMetadata.TypeTrees[i]
+		fmt.Fprintf(w, "Type %d (ClassID: %d)", i, typeTree.ClassID)
+		if i < len(typeMetadata.Hashes) && len(typeMetadata.Hashes[i].Hash) > 0 {
+			fmt.Fprintf(w, " Hash: %s", hex.EncodeToString(typeMetadata.Hashes[i].Hash))
+		}
+		fmt.Fprintln(w)
+		dumpTypeTree(w, typeTree, 1)
+		fmt.Fprintln(w)
+	}
+}
+
+// dumpTypeTree ノードごとに型、名前、サイズ、フラグをインデントして出力する
+func dumpTypeTree(w *bufio.Writer, typeTree *TypeTree, depth int) {
+	isArray := 0
+	if typeTree.IsArray {
+		isArray = 1
+	}
+	fmt.Fprintf(w, "%s%s %s // ByteSize{%d}, Index{%d}, Version{%d}, IsArray{%d}, MetaFlag{%x}\n",
+		strings.Repeat("\t", depth), typeTree.Type, typeTree.Name, typeTree.Size, typeTree.Index, typeTree.Version, isArray, typeTree.Flags)
+	for i := range typeTree.Children {
+		dumpTypeTree(w, &typeTree.Children[i], depth+1)
+	}
+}
+
+func (f *SerializedFile) dumpObject(w *bufio.Writer, obj *ObjectInfo) {
+	typeTree, err := f.TypeTree(obj)
+	if err != nil {
+		fmt.Fprintf(w, "ID: %d (ClassID: %d)\n", obj.PathID, obj.ClassID)
+		fmt.Fprintf(w, "\t<error: %v>\n\n", err)
+		return
+	}
+	fmt.Fprintf(w, "ID: %d (ClassID: %d) %s\n", obj.PathID, obj.ClassID, typeTree.Type)
+
+	r, err := f.ObjectReader(obj.PathID)
+	if err != nil {
+		fmt.Fprintf(w, "\t<error: %v>\n\n", err)
+		return
+	}
+
+	value, err := ReadObjectValue(typeTree, r)
+	if err != nil {
+		fmt.Fprintf(w, "\t<error: %v>\n\n", err)
+		return
+	}
+
+	for _, field := range value.Fields {
+		dumpObjectValue(w, field, 1)
+	}
+	fmt.Fprintln(w)
+}
+
+// dumpObjectValue 値を "名前 値 (型)" の形式でインデントして出力する
+// 配列とmapはsizeとdataの要素を子として出力する
+func dumpObjectValue(w *bufio.Writer, value *ObjectValue, depth int) {
+	indent := strings.Repeat("\t", depth)
+
+	switch value.Kind {
+	case ValueKindPrimitive:
+		fmt.Fprintf(w, "%s%s %s (%s)\n", indent, value.Name, formatDumpPrimitive(value.Value), value.Type)
+	case ValueKindString:
+		fmt.Fprintf(w, "%s%s %q (%s)\n", indent, value.Name, value.Value, value.Type)
+	case ValueKindTypelessData:
+		b, _ := value.Value.([]byte)
+		fmt.Fprintf(w, "%s%s (%s) %d bytes", indent, value.Name, value.Type, len(b))
+		if len(b) > 0 {
+			if len(b) > dumpTypelessDataLimit {
+				fmt.Fprintf(w, ": %s...", hex.EncodeToString(b[:dumpTypelessDataLimit]))
+			} else {
+				fmt.Fprintf(w, ": %s", hex.EncodeToString(b))
+			}
+		}
+		fmt.Fprintln(w)
+	case ValueKindArray, ValueKindMap:
+		fmt.Fprintf(w, "%s%s  (%s)\n", indent, value.Name, value.Type)
+		fmt.Fprintf(w, "%s\tsize %d (int)\n", indent, len(value.Elements))
+		for _, element := range value.Elements {
+			dumpObjectValue(w, element, depth+1)
+		}
+	default:
+		fmt.Fprintf(w, "%s%s  (%s)\n", indent, value.Name, value.Type)
+		for _, field := range value.Fields {
+			dumpObjectValue(w, field, depth+1)
+		}
+	}
+}
+
+// formatDumpPrimitive プリミティブ値を文字列にする。浮動小数点数は最短表現
+func formatDumpPrimitive(v interface{}) string {
+	switch f := v.(type) {
+	case float32:
+		return strconv.FormatFloat(float64(f), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
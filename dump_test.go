@@ -0,0 +1,41 @@
+package unity
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	f := testSerializedFile(22, true)
+	_, err := f.WriteTo(&bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = f.Dump(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := buf.String()
+
+	for _, want := range []string{
+		"Format: 22\n",
+		"Unity version: 2020.3.1f1\n",
+		"path(1): \"library/unity default resources\" GUID: 01010101010101010101010101010101 Type: 0\n",
+		"\tstring m_Name // ByteSize{-1}, Index{0}, Version{0}, IsArray{0}, MetaFlag{8000}\n",
+		"ID: 1 (ClassID: 1001) Test\n",
+		"\tm_Name \"hello\" (string)\n",
+		"\tm_Enabled true (bool)\n",
+		"\tm_Ints  (vector)\n\t\tsize 3 (int)\n\t\tdata 1 (int)\n\t\tdata -2 (int)\n",
+		"\t\tdata  (pair)\n\t\t\tfirst \"a\" (string)\n\t\t\tsecond 10 (SInt64)\n",
+		"\tm_Blob (TypelessData) 3 bytes: 090807\n",
+		"\tm_Float 1.5 (float)\n",
+		"ID: 2 (ClassID: 1001) Test\n\t<error: ",
+	} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("dump does not contain %q\n%s", want, dump)
+		}
+	}
+}
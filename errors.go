@@ -12,4 +12,52 @@ var ErrUnsupportedPlayerVersion = errors.New("Unsupported player version")
 var ErrUnsupportedCompressionType = errors.New("Unsupported compression type")
 
 // ErrNotImplemented 未実装
-var ErrNotImplemented = errors.New("TBD")
\ No newline at end of file
+var ErrNotImplemented = errors.New("TBD")
+
+// ErrInvalidBlockSize ブロックサイズが不正
+var ErrInvalidBlockSize = errors.New("Invalid block size")
+
+// ErrInvalidNodeRange ノードの範囲がデータ外
+var ErrInvalidNodeRange = errors.New("Invalid node range")
+
+// ErrInvalidGUID GUIDの長さが不正
+var ErrInvalidGUID = errors.New("Invalid GUID")
+
+// ErrInvalidWebDataType 不正なWebGLデータパッケージ形式
+var ErrInvalidWebDataType = errors.New("Invalid WebGL data package type")
+
+// ErrFileNotFound ファイルが見つからない
+var ErrFileNotFound = errors.New("File not found")
+
+// ErrInvalidTypeID オブジェクトの型IDが型情報の範囲外
+var ErrInvalidTypeID = errors.New("Invalid type ID")
+
+// ErrObjectNotFound オブジェクトが見つからない
+var ErrObjectNotFound = errors.New("Object not found")
+
+// ErrInvalidObjectRange オブジェクトの範囲がファイル外
+var ErrInvalidObjectRange = errors.New("Invalid object range")
+
+// ErrTypeTreeNotFound オブジェクトに対応するtype treeが見つからない
+var ErrTypeTreeNotFound = errors.New("Type tree not found")
+
+// ErrInvalidTypeTree type treeの構造が不正
+var ErrInvalidTypeTree = errors.New("Invalid type tree")
+
+// ErrValueTypeMismatch 値がtype treeの型と一致しない
+var ErrValueTypeMismatch = errors.New("Value does not match type tree")
+
+// ErrInvalidHashSize ハッシュまたはスクリプトIDが16バイトではない
+var ErrInvalidHashSize = errors.New("Invalid hash size")
+
+// ErrSerializedFileTooLarge format 22未満で表現できないファイルサイズ
+var ErrSerializedFileTooLarge = errors.New("Serialized file too large for format")
+
+// ErrResourceNotFound ストリーミングデータの参照先のリソースが登録されていない
+var ErrResourceNotFound = errors.New("Resource not found")
+
+// ErrInvalidResourceRange ストリーミングデータの範囲がリソース外
+var ErrInvalidResourceRange = errors.New("Invalid resource range")
+
+// ErrInvalidTypeTreeDatabase type treeデータベースの形式が不正
+var ErrInvalidTypeTreeDatabase = errors.New("Invalid type tree database")
@@ -0,0 +1,7 @@
+package unity
+
+// unity_testパッケージのテスト (生成した構造体のデコード) から使うテスト用のデータ
+var (
+	SampleTypeTree    = testTypeTree
+	SampleObjectBytes = testObjectBytes
+)
@@ -1,16 +1,19 @@
 package unity
 
+// ObjectInfo オブジェクトテーブルの1エントリ
+// format 16以降はClassIDを持たないため、TypeIDが指す型情報から補完する
 type ObjectInfo struct {
-	PathID     int64
-	DataOffset uint32
-	Size       uint32
-	TypeID     int32
-	ClassID    ClassID
+	PathID          int64
+	DataOffset      int64
+	Size            uint32
+	TypeID          int32
+	ClassID         ClassID
+	IsDestroyed     uint16
+	ScriptTypeIndex int16
+	Stripped        uint8
 }
 
 func ParseObjectInfo(dataReader *DataReader, format uint32, isLongObjectIDs, isLittleEndian bool) (*ObjectInfo, error) {
-	// pp.Println("format", format)
-
 	var err error
 	if format >= 14 {
 		err = dataReader.Align()
@@ -41,52 +44,62 @@ func ParseObjectInfo(dataReader *DataReader, format uint32, isLongObjectIDs, isL
 		}
 	}
 	obj.PathID = pathID
-	// pp.Println("pathID", pathID)
 
-	objDataOffset, err := dataReader.ReadUint(isLittleEndian)
-	if err != nil {
-		return nil, err
+	if format >= 22 {
+		objDataOffset, err := dataReader.ReadLong(isLittleEndian)
+		if err != nil {
+			return nil, err
+		}
+		obj.DataOffset = objDataOffset
+	} else {
+		objDataOffset, err := dataReader.ReadUint(isLittleEndian)
+		if err != nil {
+			return nil, err
+		}
+		obj.DataOffset = int64(objDataOffset)
 	}
-	obj.DataOffset = objDataOffset
-	// pp.Println("objDataOffset", objDataOffset)
 
 	objSize, err := dataReader.ReadUint(isLittleEndian)
 	if err != nil {
 		return nil, err
 	}
 	obj.Size = objSize
-	// pp.Println("objSize", objSize)
 
 	objTypeID, err := dataReader.ReadInt(isLittleEndian)
 	if err != nil {
 		return nil, err
 	}
 	obj.TypeID = objTypeID
-	// pp.Println("objTypeID", objTypeID)
 
-	objClassID, err := dataReader.ReadShort(isLittleEndian)
-	if err != nil {
-		return nil, err
+	if format < 16 {
+		objClassID, err := dataReader.ReadUshort(isLittleEndian)
+		if err != nil {
+			return nil, err
+		}
+		obj.ClassID = ClassID(objClassID)
 	}
-	obj.ClassID = ClassID(objClassID)
-	// pp.Println("ClassID", ClassID(objClassID))
 
-	if format <= 10 {
-		_, err = dataReader.ReadShort(isLittleEndian)
+	obj.ScriptTypeIndex = -1
+	if format < 11 {
+		isDestroyed, err := dataReader.ReadUshort(isLittleEndian)
 		if err != nil {
 			return nil, err
 		}
-	} else if format >= 11 {
-		_, err = dataReader.ReadShort(isLittleEndian)
+		obj.IsDestroyed = isDestroyed
+	} else if format < 17 {
+		scriptTypeIndex, err := dataReader.ReadShort(isLittleEndian)
 		if err != nil {
 			return nil, err
 		}
-		if format >= 15 {
-			_, err = dataReader.ReadChar(isLittleEndian)
-			if err != nil {
-				return nil, err
-			}
+		obj.ScriptTypeIndex = scriptTypeIndex
+	}
+
+	if format == 15 || format == 16 {
+		stripped, err := dataReader.ReadUchar(isLittleEndian)
+		if err != nil {
+			return nil, err
 		}
+		obj.Stripped = stripped
 	}
 	return &obj, nil
 }
@@ -0,0 +1,156 @@
+package unity
+
+import "math"
+
+// ObjectReader オブジェクトのデータ範囲に限定したリーダー
+// 位置はオブジェクトの先頭からの相対位置で、Alignもオブジェクト先頭を基準にする
+type ObjectReader struct {
+	*DataReader
+	Object         *ObjectInfo
+	IsLittleEndian bool
+}
+
+// ObjectData オブジェクトのデータを返す
+// SetObjectDataで置き換えられている場合は置き換え後のデータを返す
+func (f *SerializedFile) ObjectData(obj *ObjectInfo) ([]byte, error) {
+	if data, ok := f.objectData[obj.PathID]; ok {
+		return data, nil
+	}
+
+	start := f.DataOffset + obj.DataOffset
+	end := start + int64(obj.Size)
+	if obj.DataOffset < 0 || start < 0 || end > int64(len(f.data)) {
+		return nil, ErrInvalidObjectRange
+	}
+	if f.FileSize > 0 && end > f.FileSize {
+		return nil, ErrInvalidObjectRange
+	}
+	return f.data[start:end], nil
+}
+
+// SetObjectData オブジェクトのデータを置き換える。WriteToで書き出す際に反映される
+func (f *SerializedFile) SetObjectData(pathID int64, data []byte) error {
+	obj, ok := f.Object(pathID)
+	if !ok {
+		return ErrObjectNotFound
+	}
+	if uint64(len(data)) > math.MaxUint32 {
+		return ErrInvalidObjectRange
+	}
+
+	if f.objectData == nil {
+		f.objectData = map[int64][]byte{}
+	}
+	f.objectData[pathID] = data
+	obj.Size = uint32(len(data))
+	return nil
+}
+
+// ObjectReader PathIDに対応するオブジェクトのリーダーを返す
+func (f *SerializedFile) ObjectReader(pathID int64) (*ObjectReader, error) {
+	obj, ok := f.Object(pathID)
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+
+	data, err := f.ObjectData(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	dataReader, err := NewDataReader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ObjectReader{
+		DataReader:     dataReader,
+		Object:         obj,
+		IsLittleEndian: f.IsLittleEndian,
+	}, nil
+}
+
+// ReadBool read bool
+func (r *ObjectReader) ReadBool() (bool, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return false, err
+	}
+	return b != 0, nil
+}
+
+// ReadInt8 read SInt8
+func (r *ObjectReader) ReadInt8() (int8, error) {
+	return r.ReadChar(r.IsLittleEndian)
+}
+
+// ReadUint8 read UInt8
+func (r *ObjectReader) ReadUint8() (uint8, error) {
+	return r.ReadUchar(r.IsLittleEndian)
+}
+
+// ReadInt16 read SInt16
+func (r *ObjectReader) ReadInt16() (int16, error) {
+	return r.ReadShort(r.IsLittleEndian)
+}
+
+// ReadUint16 read UInt16
+func (r *ObjectReader) ReadUint16() (uint16, error) {
+	return r.ReadUshort(r.IsLittleEndian)
+}
+
+// ReadInt32 read SInt32
+func (r *ObjectReader) ReadInt32() (int32, error) {
+	return r.ReadInt(r.IsLittleEndian)
+}
+
+// ReadUint32 read UInt32
+func (r *ObjectReader) ReadUint32() (uint32, error) {
+	return r.ReadUint(r.IsLittleEndian)
+}
+
+// ReadInt64 read SInt64
+func (r *ObjectReader) ReadInt64() (int64, error) {
+	return r.ReadLong(r.IsLittleEndian)
+}
+
+// ReadUint64 read UInt64
+func (r *ObjectReader) ReadUint64() (uint64, error) {
+	return r.ReadUlong(r.IsLittleEndian)
+}
+
+// ReadFloat32 read float
+func (r *ObjectReader) ReadFloat32() (float32, error) {
+	return r.ReadFloat(r.IsLittleEndian)
+}
+
+// ReadFloat64 read double
+func (r *ObjectReader) ReadFloat64() (float64, error) {
+	return r.ReadDouble(r.IsLittleEndian)
+}
+
+// ReadString 長さ (SInt32) 付きの文字列を読み込む
+func (r *ObjectReader) ReadString() (string, error) {
+	size, err := r.ReadInt32()
+	if err != nil {
+		return "", err
+	}
+	if size < 0 || int(size) > r.Len() {
+		return "", ErrInvalidObjectRange
+	}
+
+	b, err := r.ReadBytes(int(size), r.IsLittleEndian)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// ReadAlignedString 長さ付きの文字列を読み込み4バイト境界に揃える
+func (r *ObjectReader) ReadAlignedString() (string, error) {
+	s, err := r.ReadString()
+	if err != nil {
+		return "", err
+	}
+	return s, r.Align()
+}
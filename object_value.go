@@ -0,0 +1,569 @@
+package unity
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// typeTreeFlagAlignBytes 読み込み後に4バイト境界に揃えるフラグ (kAlignBytesFlag)
+const typeTreeFlagAlignBytes = 0x4000
+
+type valueKind int
+
+const (
+	// ValueKindStruct フィールドを持つ構造体
+	ValueKindStruct valueKind = iota
+
+	// ValueKindArray 配列 (vector / Array)
+	ValueKindArray
+
+	// ValueKindMap map。Elementsはpairの並び
+	ValueKindMap
+
+	// ValueKindPair pair。Fieldsはfirst, secondの2要素
+	ValueKindPair
+
+	// ValueKindString 文字列
+	ValueKindString
+
+	// ValueKindPrimitive 数値またはbool
+	ValueKindPrimitive
+
+	// ValueKindTypelessData 型を持たないバイト列
+	ValueKindTypelessData
+)
+
+// ObjectValue type treeに従ってデコードした値
+// Valueはプリミティブの場合type treeの型に対応するGoの型 (int32, float32, bool等)、
+// 文字列の場合string、TypelessDataの場合[]byteを保持する
+type ObjectValue struct {
+	Kind     valueKind
+	Type     string
+	Name     string
+	Value    interface{}
+	Fields   []*ObjectValue
+	Elements []*ObjectValue
+}
+
+// Field 名前が一致するフィールドを返す
+func (v *ObjectValue) Field(name string) (*ObjectValue, bool) {
+	for _, field := range v.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return nil, false
+}
+
+// isAligned ノードの読み込み後に4バイト境界に揃えるかどうか
+func (t *TypeTree) isAligned() bool {
+	return t.Flags&typeTreeFlagAlignBytes != 0
+}
+
+// arrayNode vector等のようにArrayを唯一の子に持つ場合はそのArrayノードを返す
+func (t *TypeTree) arrayNode() (*TypeTree, bool) {
+	if len(t.Children) == 1 && t.Children[0].IsArray && len(t.Children[0].Children) == 2 {
+		return &t.Children[0], true
+	}
+	return nil, false
+}
+
+// alignsAfter ノードの値を読み込んだ後に4バイト境界に揃えるかどうか
+// string / vector / mapはフラグが子のArrayノードに付いている
+func (t *TypeTree) alignsAfter() bool {
+	if t.isAligned() {
+		return true
+	}
+	if arr, ok := t.arrayNode(); ok {
+		return arr.isAligned()
+	}
+	return false
+}
+
+// primitiveType プリミティブ型に対応するGoの型とObjectReaderの読み込みメソッド
+type primitiveType struct {
+	goType     string
+	readMethod string
+	read       func(r *ObjectReader) (interface{}, error)
+}
+
+// primitiveTypes type treeの型名からプリミティブ型を引く
+var primitiveTypes = map[string]primitiveType{}
+
+func init() {
+	for _, p := range []struct {
+		typeNames []string
+		primitiveType
+	}{
+		{[]string{"bool"}, primitiveType{"bool", "ReadBool", func(r *ObjectReader) (interface{}, error) { return r.ReadBool() }}},
+		{[]string{"SInt8"}, primitiveType{"int8", "ReadInt8", func(r *ObjectReader) (interface{}, error) { return r.ReadInt8() }}},
+		{[]string{"UInt8", "char"}, primitiveType{"uint8", "ReadUint8", func(r *ObjectReader) (interface{}, error) { return r.ReadUint8() }}},
+		{[]string{"short", "SInt16"}, primitiveType{"int16", "ReadInt16", func(r *ObjectReader) (interface{}, error) { return r.ReadInt16() }}},
+		{[]string{"UInt16", "unsigned short"}, primitiveType{"uint16", "ReadUint16", func(r *ObjectReader) (interface{}, error) { return r.ReadUint16() }}},
+		{[]string{"int", "SInt32"}, primitiveType{"int32", "ReadInt32", func(r *ObjectReader) (interface{}, error) { return r.ReadInt32() }}},
+		{[]string{"UInt32", "unsigned int", "Type*"}, primitiveType{"uint32", "ReadUint32", func(r *ObjectReader) (interface{}, error) { return r.ReadUint32() }}},
+		{[]string{"long long", "SInt64"}, primitiveType{"int64", "ReadInt64", func(r *ObjectReader) (interface{}, error) { return r.ReadInt64() }}},
+		{[]string{"UInt64", "unsigned long long", "FileSize"}, primitiveType{"uint64", "ReadUint64", func(r *ObjectReader) (interface{}, error) { return r.ReadUint64() }}},
+		{[]string{"float"}, primitiveType{"float32", "ReadFloat32", func(r *ObjectReader) (interface{}, error) { return r.ReadFloat32() }}},
+		{[]string{"double"}, primitiveType{"float64", "ReadFloat64", func(r *ObjectReader) (interface{}, error) { return r.ReadFloat64() }}},
+	} {
+		for _, typeName := range p.typeNames {
+			primitiveTypes[typeName] = p.primitiveType
+		}
+	}
+}
+
+// ReadObjectValue type treeに従いオブジェクトをデコード
+func ReadObjectValue(typeTree *TypeTree, r *ObjectReader) (*ObjectValue, error) {
+	return readObjectValue(typeTree, r)
+}
+
+func readObjectValue(node *TypeTree, r *ObjectReader) (*ObjectValue, error) {
+	value := &ObjectValue{
+		Type: node.Type,
+		Name: node.Name,
+	}
+	align := node.alignsAfter()
+
+	primitive, isPrimitive, err := readPrimitiveValue(node.Type, r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case isPrimitive:
+		value.Kind = ValueKindPrimitive
+		value.Value = primitive
+	case node.Type == "string":
+		value.Kind = ValueKindString
+		s, err := r.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		value.Value = s
+	case node.Type == "TypelessData":
+		value.Kind = ValueKindTypelessData
+		size, err := readArraySize(r)
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.ReadBytes(size, r.IsLittleEndian)
+		if err != nil {
+			return nil, err
+		}
+		value.Value = b
+	case node.IsArray && len(node.Children) == 2:
+		value.Kind = ValueKindArray
+		elements, err := readArrayElements(node, r)
+		if err != nil {
+			return nil, err
+		}
+		value.Elements = elements
+	case node.Type == "map":
+		value.Kind = ValueKindMap
+		arr, ok := node.arrayNode()
+		if !ok {
+			return nil, ErrInvalidTypeTree
+		}
+		elements, err := readArrayElements(arr, r)
+		if err != nil {
+			return nil, err
+		}
+		value.Elements = elements
+	default:
+		if arr, ok := node.arrayNode(); ok {
+			value.Kind = ValueKindArray
+			elements, err := readArrayElements(arr, r)
+			if err != nil {
+				return nil, err
+			}
+			value.Elements = elements
+			break
+		}
+
+		value.Kind = ValueKindStruct
+		if node.Type == "pair" {
+			value.Kind = ValueKindPair
+		}
+		for i := range node.Children {
+			field, err := readObjectValue(&node.Children[i], r)
+			if err != nil {
+				return nil, err
+			}
+			value.Fields = append(value.Fields, field)
+		}
+	}
+
+	if align {
+		err = r.Align()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// readArraySize 配列の要素数を読み込む
+func readArraySize(r *ObjectReader) (int, error) {
+	size, err := r.ReadInt32()
+	if err != nil {
+		return 0, err
+	}
+	if size < 0 || int(size) > r.Len() {
+		return 0, ErrInvalidObjectRange
+	}
+	return int(size), nil
+}
+
+// readArrayElements Arrayノード (size, data) に従い要素を読み込む
+func readArrayElements(arr *TypeTree, r *ObjectReader) ([]*ObjectValue, error) {
+	size, err := readArraySize(r)
+	if err != nil {
+		return nil, err
+	}
+
+	elements := []*ObjectValue{}
+	for i := 0; i < size; i++ {
+		element, err := readObjectValue(&arr.Children[1], r)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+	}
+
+	if arr.isAligned() {
+		err = r.Align()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return elements, nil
+}
+
+// readPrimitiveValue 型名がプリミティブの場合に値を読み込む
+func readPrimitiveValue(typeName string, r *ObjectReader) (interface{}, bool, error) {
+	primitive, ok := primitiveTypes[typeName]
+	if !ok {
+		return nil, false, nil
+	}
+	v, err := primitive.read(r)
+	if err != nil {
+		return nil, true, err
+	}
+	return v, true, nil
+}
+
+// TypeTree オブジェクトに対応するtype treeを返す
+// type treeが除かれている場合はtype treeデータベースから探す
+func (f *SerializedFile) TypeTree(obj *ObjectInfo) (*TypeTree, error) {
+	typeMetadata := f.TypeMetadata
+	if typeMetadata == nil || !typeMetadata.HasTypeTrees {
+		return f.typeTreeFromDatabase(obj)
+	}
+
+	if f.Format >= 16 {
+		if obj.TypeID < 0 || int(obj.TypeID) >= len(typeMetadata.TypeTrees) {
+			return nil, ErrInvalidTypeID
+		}
+		return &typeMetadata.TypeTrees[obj.TypeID], nil
+	}
+
+	for i, hash := range typeMetadata.Hashes {
+		if hash.ClassID == ClassID(obj.TypeID) && i < len(typeMetadata.TypeTrees) {
+			return &typeMetadata.TypeTrees[i], nil
+		}
+	}
+	return nil, ErrTypeTreeNotFound
+}
+
+// ReadObjectValue PathIDに対応するオブジェクトをtype treeに従いデコード
+func (f *SerializedFile) ReadObjectValue(pathID int64) (*ObjectValue, error) {
+	r, err := f.ObjectReader(pathID)
+	if err != nil {
+		return nil, err
+	}
+
+	typeTree, err := f.TypeTree(r.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	return ReadObjectValue(typeTree, r)
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+// 構造体はフィールド順を保ったオブジェクト、配列とmapは配列、pairは{"first", "second"}として出力する
+func (v *ObjectValue) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	err := v.writeJSON(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (v *ObjectValue) writeJSON(buf *bytes.Buffer) error {
+	switch v.Kind {
+	case ValueKindStruct, ValueKindPair:
+		buf.WriteByte('{')
+		for i, field := range v.Fields {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			name := field.Name
+			if v.Kind == ValueKindPair && i < 2 {
+				name = []string{"first", "second"}[i]
+			}
+			key, err := json.Marshal(name)
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			err = field.writeJSON(buf)
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case ValueKindArray, ValueKindMap:
+		buf.WriteByte('[')
+		for i, element := range v.Elements {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			err := element.writeJSON(buf)
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+
+	// NaN / Infinity はJSONで表現できないため文字列にする
+	switch f := v.Value.(type) {
+	case float32:
+		if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+			buf.WriteString(strconv.Quote(strconv.FormatFloat(float64(f), 'g', -1, 32)))
+			return nil
+		}
+	case float64:
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			buf.WriteString(strconv.Quote(strconv.FormatFloat(f, 'g', -1, 64)))
+			return nil
+		}
+	}
+
+	b, err := json.Marshal(v.Value)
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
+// WriteObjectValue type treeに従い値をオブジェクトのバイナリに書き出す
+func WriteObjectValue(typeTree *TypeTree, value *ObjectValue, isLittleEndian bool) ([]byte, error) {
+	w := NewDataWriter()
+	err := writeObjectValue(typeTree, value, w, isLittleEndian)
+	if err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+func writeObjectValue(node *TypeTree, value *ObjectValue, w *DataWriter, isLittleEndian bool) error {
+	align := node.alignsAfter()
+
+	isPrimitive, err := writePrimitiveValue(node.Type, value.Value, w, isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case isPrimitive:
+	case node.Type == "string":
+		s, ok := value.Value.(string)
+		if !ok {
+			return ErrValueTypeMismatch
+		}
+		err = w.WriteInt(int32(len(s)), isLittleEndian)
+		if err != nil {
+			return err
+		}
+		err = w.WriteBytes([]byte(s))
+		if err != nil {
+			return err
+		}
+	case node.Type == "TypelessData":
+		b, ok := value.Value.([]byte)
+		if !ok {
+			return ErrValueTypeMismatch
+		}
+		err = w.WriteInt(int32(len(b)), isLittleEndian)
+		if err != nil {
+			return err
+		}
+		err = w.WriteBytes(b)
+		if err != nil {
+			return err
+		}
+	case node.IsArray && len(node.Children) == 2:
+		err = writeArrayElements(node, value.Elements, w, isLittleEndian)
+		if err != nil {
+			return err
+		}
+	case node.Type == "map":
+		arr, ok := node.arrayNode()
+		if !ok {
+			return ErrInvalidTypeTree
+		}
+		err = writeArrayElements(arr, value.Elements, w, isLittleEndian)
+		if err != nil {
+			return err
+		}
+	default:
+		if arr, ok := node.arrayNode(); ok {
+			err = writeArrayElements(arr, value.Elements, w, isLittleEndian)
+			if err != nil {
+				return err
+			}
+			break
+		}
+
+		if len(value.Fields) != len(node.Children) {
+			return ErrValueTypeMismatch
+		}
+		for i := range node.Children {
+			err = writeObjectValue(&node.Children[i], value.Fields[i], w, isLittleEndian)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if align {
+		return w.Align()
+	}
+	return nil
+}
+
+// writeArrayElements Arrayノード (size, data) に従い要素を書き出す
+func writeArrayElements(arr *TypeTree, elements []*ObjectValue, w *DataWriter, isLittleEndian bool) error {
+	err := w.WriteInt(int32(len(elements)), isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	for _, element := range elements {
+		err = writeObjectValue(&arr.Children[1], element, w, isLittleEndian)
+		if err != nil {
+			return err
+		}
+	}
+
+	if arr.isAligned() {
+		return w.Align()
+	}
+	return nil
+}
+
+// writePrimitiveValue 型名がプリミティブの場合に値を書き出す
+// 値はGoの任意の数値型を受け付け、type treeの型の幅に変換する
+func writePrimitiveValue(typeName string, v interface{}, w *DataWriter, isLittleEndian bool) (bool, error) {
+	var err error
+	switch typeName {
+	case "bool":
+		b, ok := v.(bool)
+		if !ok {
+			return true, ErrValueTypeMismatch
+		}
+		var c byte
+		if b {
+			c = 1
+		}
+		err = w.WriteByte(c)
+	case "SInt8", "UInt8", "char":
+		i, ok := toInt64(v)
+		if !ok {
+			return true, ErrValueTypeMismatch
+		}
+		err = w.WriteByte(byte(i))
+	case "short", "SInt16", "UInt16", "unsigned short":
+		i, ok := toInt64(v)
+		if !ok {
+			return true, ErrValueTypeMismatch
+		}
+		err = w.WriteUshort(uint16(i), isLittleEndian)
+	case "int", "SInt32", "UInt32", "unsigned int", "Type*":
+		i, ok := toInt64(v)
+		if !ok {
+			return true, ErrValueTypeMismatch
+		}
+		err = w.WriteUint(uint32(i), isLittleEndian)
+	case "long long", "SInt64", "UInt64", "unsigned long long", "FileSize":
+		i, ok := toInt64(v)
+		if !ok {
+			return true, ErrValueTypeMismatch
+		}
+		err = w.WriteUlong(uint64(i), isLittleEndian)
+	case "float":
+		f, ok := toFloat64(v)
+		if !ok {
+			return true, ErrValueTypeMismatch
+		}
+		err = w.WriteUint(math.Float32bits(float32(f)), isLittleEndian)
+	case "double":
+		f, ok := toFloat64(v)
+		if !ok {
+			return true, ErrValueTypeMismatch
+		}
+		err = w.WriteUlong(math.Float64bits(f), isLittleEndian)
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+// toInt64 整数型の値をint64のビット列として返す (uint64はそのままのビットを保持する)
+func toInt64(v interface{}) (int64, bool) {
+	switch i := v.(type) {
+	case int:
+		return int64(i), true
+	case int8:
+		return int64(i), true
+	case int16:
+		return int64(i), true
+	case int32:
+		return int64(i), true
+	case int64:
+		return i, true
+	case uint:
+		return int64(i), true
+	case uint8:
+		return int64(i), true
+	case uint16:
+		return int64(i), true
+	case uint32:
+		return int64(i), true
+	case uint64:
+		return int64(i), true
+	}
+	return 0, false
+}
+
+// toFloat64 数値型の値をfloat64として返す
+func toFloat64(v interface{}) (float64, bool) {
+	switch f := v.(type) {
+	case float32:
+		return float64(f), true
+	case float64:
+		return f, true
+	}
+	i, ok := toInt64(v)
+	return float64(i), ok
+}
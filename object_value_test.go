@@ -0,0 +1,157 @@
+package unity
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func testTypeTree() *TypeTree {
+	array := func(data TypeTree, flags int32) TypeTree {
+		return TypeTree{Type: "Array", Name: "Array", IsArray: true, TypeFlags: 1, Size: -1, Flags: flags, Children: []TypeTree{
+			{Type: "int", Name: "size", Size: 4},
+			data,
+		}}
+	}
+	str := func(name string) TypeTree {
+		return TypeTree{Type: "string", Name: name, Size: -1, Flags: 0x8000, Children: []TypeTree{
+			array(TypeTree{Type: "char", Name: "data", Size: 1}, 0x4001),
+		}}
+	}
+	return &TypeTree{Type: "Test", Name: "Base", Size: -1, Children: []TypeTree{
+		str("m_Name"),
+		{Type: "bool", Name: "m_Enabled", Size: 1, Flags: 0x4000},
+		{Type: "vector", Name: "m_Ints", Size: -1, Children: []TypeTree{
+			array(TypeTree{Type: "int", Name: "data", Size: 4}, 0),
+		}},
+		{Type: "map", Name: "m_Map", Size: -1, Children: []TypeTree{
+			array(TypeTree{Type: "pair", Name: "data", Size: -1, Children: []TypeTree{
+				str("first"),
+				{Type: "SInt64", Name: "second", Size: 8},
+			}}, 0),
+		}},
+		{Type: "TypelessData", Name: "m_Blob", Size: -1, Flags: 0x4000, Children: []TypeTree{
+			{Type: "int", Name: "size", Size: 4},
+			{Type: "UInt8", Name: "data", Size: 1},
+		}},
+		{Type: "float", Name: "m_Float", Size: 4},
+	}}
+}
+
+func testObjectBytes(order binary.ByteOrder) []byte {
+	var b bytes.Buffer
+	align := func() {
+		for b.Len()%4 != 0 {
+			b.WriteByte(0)
+		}
+	}
+	writeString := func(s string) {
+		binary.Write(&b, order, int32(len(s)))
+		b.WriteString(s)
+		align()
+	}
+
+	writeString("hello")
+	b.WriteByte(1)
+	align()
+	binary.Write(&b, order, []int32{3, 1, -2, 3})
+	binary.Write(&b, order, int32(2))
+	writeString("a")
+	binary.Write(&b, order, int64(10))
+	writeString("bb")
+	binary.Write(&b, order, int64(-20))
+	binary.Write(&b, order, int32(3))
+	b.Write([]byte{9, 8, 7})
+	align()
+	binary.Write(&b, order, float32(1.5))
+	return b.Bytes()
+}
+
+func TestWriteObjectValueRoundTrip(t *testing.T) {
+	for _, isLittleEndian := range []bool{true, false} {
+		var order binary.ByteOrder = binary.BigEndian
+		if isLittleEndian {
+			order = binary.LittleEndian
+		}
+		data := testObjectBytes(order)
+
+		dataReader, err := NewDataReader(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := ReadObjectValue(testTypeTree(), &ObjectReader{DataReader: dataReader, IsLittleEndian: isLittleEndian})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dataReader.Len() != 0 {
+			t.Fatalf("%d bytes left unread", dataReader.Len())
+		}
+
+		written, err := WriteObjectValue(testTypeTree(), value, isLittleEndian)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(written, data) {
+			t.Fatalf("little endian %v: re-serialized data differs\n got %x\nwant %x", isLittleEndian, written, data)
+		}
+
+		name, _ := value.Field("m_Name")
+		name.Value = "modified name"
+		written, err = WriteObjectValue(testTypeTree(), value, isLittleEndian)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dataReader, err = NewDataReader(written)
+		if err != nil {
+			t.Fatal(err)
+		}
+		modified, err := ReadObjectValue(testTypeTree(), &ObjectReader{DataReader: dataReader, IsLittleEndian: isLittleEndian})
+		if err != nil {
+			t.Fatal(err)
+		}
+		name, _ = modified.Field("m_Name")
+		f, _ := modified.Field("m_Float")
+		if name.Value != "modified name" || f.Value != float32(1.5) {
+			t.Fatalf("unexpected values after modification: %v, %v", name.Value, f.Value)
+		}
+	}
+}
+
+func TestObjectValueMarshalJSON(t *testing.T) {
+	dataReader, err := NewDataReader(testObjectBytes(binary.LittleEndian))
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := ReadObjectValue(testTypeTree(), &ObjectReader{DataReader: dataReader, IsLittleEndian: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"m_Name":"hello","m_Enabled":true,"m_Ints":[1,-2,3],` +
+		`"m_Map":[{"first":"a","second":10},{"first":"bb","second":-20}],"m_Blob":"CQgH","m_Float":1.5}`
+	if string(b) != want {
+		t.Fatalf("unexpected JSON\n got %s\nwant %s", b, want)
+	}
+
+	// JSONで表現できない浮動小数点数と制御文字を含むフィールド名
+	value = &ObjectValue{Kind: ValueKindStruct, Fields: []*ObjectValue{
+		{Name: "m_NaN", Kind: ValueKindPrimitive, Value: float32(math.NaN())},
+		{Name: "m_Inf", Kind: ValueKindPrimitive, Value: math.Inf(-1)},
+		{Name: "m_\x01\x7f\"", Kind: ValueKindPrimitive, Value: int32(1)},
+	}}
+	b, err = json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `{"m_NaN":"NaN","m_Inf":"-Inf","m_\u0001` + "\x7f" + `\"":1}`
+	if string(b) != want || !json.Valid(b) {
+		t.Fatalf("unexpected JSON\n got %s\nwant %s", b, want)
+	}
+}
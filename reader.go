@@ -126,6 +126,26 @@ func (data *DataReader) ReadUlong(isLittleEndian bool) (uint64, error) {
 	return i, nil
 }
 
+// ReadFloat read float
+func (data *DataReader) ReadFloat(isLittleEndian bool) (float32, error) {
+	var f float32
+	err := binary.Read(data.buffer, selectByteOrder(isLittleEndian), &f)
+	if err != nil {
+		return 0, err
+	}
+	return f, nil
+}
+
+// ReadDouble read double
+func (data *DataReader) ReadDouble(isLittleEndian bool) (float64, error) {
+	var f float64
+	err := binary.Read(data.buffer, selectByteOrder(isLittleEndian), &f)
+	if err != nil {
+		return 0, err
+	}
+	return f, nil
+}
+
 func (data *DataReader) ReadBytes(size int, isLittleEndian bool) ([]byte, error) {
 	b := make([]byte, size)
 	err := binary.Read(data.buffer, selectByteOrder(isLittleEndian), &b)
@@ -171,15 +191,26 @@ func (data *DataReader) Seek(offset int64, whence int) (int64, error) {
 	return data.buffer.Seek(offset, whence)
 }
 
+// Pos 現在の読み込み位置
+func (data *DataReader) Pos() int64 {
+	return int64(len(*data.raw) - data.buffer.Len())
+}
+
 // Len Return the current stream position.
 func (data *DataReader) Len() int {
 	return data.buffer.Len()
 }
 
+// Align 4バイト境界に揃える
 func (data *DataReader) Align() error {
+	return data.AlignTo(4)
+}
+
+// AlignTo nバイト境界に揃える (nは2の累乗)
+func (data *DataReader) AlignTo(n int) error {
 	size := len(*data.raw)
 	oldPos := size - data.buffer.Len()
-	newPos := (oldPos + 3) & -4
+	newPos := (oldPos + n - 1) & -n
 	if newPos > oldPos {
 		_, err := data.buffer.Seek(int64(newPos-oldPos), os.SEEK_CUR)
 		if err != nil {
@@ -0,0 +1,196 @@
+package unity
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// resourceExtensions シリアライズファイルと同じ場所に置かれるストリーミングデータのファイルの拡張子
+var resourceExtensions = []string{".resS", ".resource"}
+
+// resourceFile パスで指定されたリソースファイル。読み込みのたびにファイルを開く
+type resourceFile string
+
+func (p resourceFile) ReadAt(b []byte, off int64) (int, error) {
+	fp, err := os.Open(string(p))
+	if err != nil {
+		return 0, err
+	}
+	defer fp.Close()
+	return fp.ReadAt(b, off)
+}
+
+// isResourceName ストリーミングデータのファイル名かどうか
+func isResourceName(name string) bool {
+	for _, ext := range resourceExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenSerializedFile ファイルパスからシリアライズファイル (globalgamemanagers / level0 / *.assets等) を開く
+// 同じディレクトリにある対応する.resS / .resourceファイルはリソースとして登録する
+func OpenSerializedFile(filePath string) (*SerializedFile, error) {
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	serializedFile, err := ParseSerializedFile(b)
+	if err != nil {
+		return nil, err
+	}
+
+	// sharedassets0.assets.resS / sharedassets0.resource の両方の命名がある
+	dir := filepath.Dir(filePath)
+	base := filepath.Base(filePath)
+	for _, name := range []string{base, strings.TrimSuffix(base, filepath.Ext(base))} {
+		for _, ext := range resourceExtensions {
+			resourcePath := filepath.Join(dir, name+ext)
+			info, err := os.Stat(resourcePath)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			serializedFile.RegisterResource(name+ext, resourceFile(resourcePath))
+		}
+	}
+
+	return serializedFile, nil
+}
+
+// ParseSerializedFile WebGLのパッケージ内のシリアライズファイル (globalgamemanagers / level0 / *.assets等) をパース
+// パッケージ内の.resS / .resourceファイルはリソースとして登録する
+func (w *WebData) ParseSerializedFile(name string) (*SerializedFile, error) {
+	b, err := w.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	serializedFile, err := ParseSerializedFile(b)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range w.Files {
+		if isResourceName(f.Name) {
+			serializedFile.RegisterResource(f.Name, bytes.NewReader(w.data[f.Offset:f.Offset+f.Size]))
+		}
+	}
+	return serializedFile, nil
+}
+
+// WalkSerializedFiles Bundleまたはシリアライズファイルを開き、含まれるシリアライズファイルごとにfnを呼ぶ
+// nameはBundle内のノード名で、シリアライズファイル単体の場合は空文字列になる
+// Bundleのリソース (.resS / .resource) 以外のノードをパースし、パースできないノードはerrとしてfnに渡す
+// Bundleのファイルはfnから戻った後に閉じるため、リソースの読み込みはfnの中で行う
+func WalkSerializedFiles(filePath string, fn func(name string, f *SerializedFile, err error) error) error {
+	fp, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	info, err := fp.Stat()
+	if err != nil {
+		return err
+	}
+
+	b, err := OpenBundle(fp, info.Size())
+	if err == ErrInvalidAssetBundleType {
+		f, err := OpenSerializedFile(filePath)
+		if err != nil {
+			return err
+		}
+		return fn("", f, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, node := range b.Nodes {
+		if isResourceName(node.Name) {
+			continue
+		}
+
+		f, err := b.ParseSerializedFile(node)
+		err = fn(node.Name, f, err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterResource ストリーミングデータの参照先となるリソースを名前で登録する
+func (f *SerializedFile) RegisterResource(name string, r io.ReaderAt) {
+	if f.resources == nil {
+		f.resources = map[string]io.ReaderAt{}
+	}
+	f.resources[name] = r
+}
+
+// ReadStreamedData リソース内のストリーミングデータを読み込む
+// resourcePathは "archive:/CAB-xxx/CAB-xxx.resS" のようなパスでもよく、ファイル名で登録済みのリソースを探す
+func (f *SerializedFile) ReadStreamedData(resourcePath string, offset, size int64) ([]byte, error) {
+	r, ok := f.resources[resourcePath]
+	if !ok {
+		r, ok = f.resources[path.Base(resourcePath)]
+	}
+	if !ok {
+		return nil, ErrResourceNotFound
+	}
+
+	if offset < 0 || size < 0 {
+		return nil, ErrInvalidResourceRange
+	}
+
+	b := make([]byte, size)
+	n, err := r.ReadAt(b, offset)
+	if n < len(b) {
+		if err == nil || err == io.EOF {
+			return nil, ErrInvalidResourceRange
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// ReadStreamingInfo StreamingInfo (offset, size, path) またはStreamedResource (m_Source, m_Offset, m_Size) の値が指すデータを読み込む
+func (f *SerializedFile) ReadStreamingInfo(value *ObjectValue) ([]byte, error) {
+	for _, names := range [][3]string{{"path", "offset", "size"}, {"m_Source", "m_Offset", "m_Size"}} {
+		source, ok := value.Field(names[0])
+		if !ok {
+			continue
+		}
+		offsetValue, ok := value.Field(names[1])
+		if !ok {
+			continue
+		}
+		sizeValue, ok := value.Field(names[2])
+		if !ok {
+			continue
+		}
+
+		resourcePath, ok := source.Value.(string)
+		if !ok {
+			return nil, ErrValueTypeMismatch
+		}
+		offset, ok := toInt64(offsetValue.Value)
+		if !ok {
+			return nil, ErrValueTypeMismatch
+		}
+		size, ok := toInt64(sizeValue.Value)
+		if !ok {
+			return nil, ErrValueTypeMismatch
+		}
+		return f.ReadStreamedData(resourcePath, offset, size)
+	}
+	return nil, ErrValueTypeMismatch
+}
@@ -0,0 +1,279 @@
+package unity
+
+import (
+	"io"
+	"os"
+)
+
+// SerializedFile シリアライズファイル (CAB-xxx / .assets / level等)
+type SerializedFile struct {
+	MetadataSize    uint32
+	FileSize        int64
+	Format          uint32
+	DataOffset      int64
+	Endianness      uint8
+	IsLittleEndian  bool
+	TypeMetadata    *TypeMetadata
+	IsLongObjectIDs bool
+	Objects         []*ObjectInfo
+	Adds            []ObjectAdd
+	Externals       []*AssetRef
+	UserInformation string
+
+	// TypeTreeDatabase type treeが除かれている場合に使うデータベース。nilの場合はDefaultTypeTreeDatabaseを使う
+	TypeTreeDatabase *TypeTreeDatabase
+
+	data        []byte
+	objectIndex map[int64]*ObjectInfo
+	objectData  map[int64][]byte
+	resources   map[string]io.ReaderAt
+}
+
+// ObjectAdd 他ファイルのオブジェクトへの参照 (LocalSerializedObjectIdentifier)
+type ObjectAdd struct {
+	FileIndex int32
+	PathID    int64
+}
+
+// ParseSerializedFile シリアライズファイルをパース
+func ParseSerializedFile(b []byte) (*SerializedFile, error) {
+	serializedFile := SerializedFile{
+		data: b,
+	}
+
+	dataReader, err := NewDataReader(b)
+	if err != nil {
+		return nil, err
+	}
+
+	metadataSize, err := dataReader.ReadUint(false)
+	if err != nil {
+		return nil, err
+	}
+	serializedFile.MetadataSize = metadataSize
+
+	fileSize, err := dataReader.ReadUint(false)
+	if err != nil {
+		return nil, err
+	}
+	serializedFile.FileSize = int64(fileSize)
+
+	format, err := dataReader.ReadUint(false)
+	if err != nil {
+		return nil, err
+	}
+	serializedFile.Format = format
+
+	dataOffset, err := dataReader.ReadUint(false)
+	if err != nil {
+		return nil, err
+	}
+	serializedFile.DataOffset = int64(dataOffset)
+
+	if format >= 9 {
+		endianness, err := dataReader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		serializedFile.Endianness = endianness
+
+		// reserved
+		_, err = dataReader.ReadBytes(3, false)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// format 8以前はメタデータがファイル末尾にあり、先頭1バイトがエンディアン
+		_, err = dataReader.Seek(serializedFile.FileSize-int64(metadataSize), os.SEEK_SET)
+		if err != nil {
+			return nil, err
+		}
+
+		endianness, err := dataReader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		serializedFile.Endianness = endianness
+	}
+	serializedFile.IsLittleEndian = serializedFile.Endianness == 0
+	isLittleEndian := serializedFile.IsLittleEndian
+
+	// format 22以降 (2020.1+) は旧ヘッダの後に64bitのサイズとオフセットが続く
+	if format >= 22 {
+		metadataSize, err := dataReader.ReadUint(false)
+		if err != nil {
+			return nil, err
+		}
+		serializedFile.MetadataSize = metadataSize
+
+		fileSize, err := dataReader.ReadLong(false)
+		if err != nil {
+			return nil, err
+		}
+		serializedFile.FileSize = fileSize
+
+		dataOffset, err := dataReader.ReadLong(false)
+		if err != nil {
+			return nil, err
+		}
+		serializedFile.DataOffset = dataOffset
+
+		// reserved
+		_, err = dataReader.ReadLong(false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	typeMetadata, err := ParseTypeMetadata(dataReader, format, isLittleEndian)
+	if err != nil {
+		return nil, err
+	}
+	serializedFile.TypeMetadata = typeMetadata
+
+	if format >= 7 && format <= 13 {
+		longObjectIDsFlag, err := dataReader.ReadUint(isLittleEndian)
+		if err != nil {
+			return nil, err
+		}
+
+		if longObjectIDsFlag > 0 {
+			serializedFile.IsLongObjectIDs = true
+		}
+	}
+
+	numObjects, err := dataReader.ReadUint(isLittleEndian)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []*ObjectInfo{}
+	for i := 0; i < int(numObjects); i++ {
+		obj, err := ParseObjectInfo(dataReader, format, serializedFile.IsLongObjectIDs, isLittleEndian)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	serializedFile.Objects = objects
+	serializedFile.indexObjects()
+
+	if format >= 16 {
+		for _, obj := range objects {
+			if obj.TypeID < 0 || int(obj.TypeID) >= len(typeMetadata.Hashes) {
+				return nil, ErrInvalidTypeID
+			}
+			obj.ClassID = typeMetadata.Hashes[obj.TypeID].ClassID
+		}
+	}
+
+	if format >= 11 {
+		numAdds, err := dataReader.ReadUint(isLittleEndian)
+		if err != nil {
+			return nil, err
+		}
+
+		adds := []ObjectAdd{}
+		for i := 0; i < int(numAdds); i++ {
+			fileIndex, err := dataReader.ReadInt(isLittleEndian)
+			if err != nil {
+				return nil, err
+			}
+
+			var pathID int64
+			if format >= 14 {
+				err = dataReader.Align()
+				if err != nil {
+					return nil, err
+				}
+
+				pathID, err = dataReader.ReadLong(isLittleEndian)
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				pathID32, err := dataReader.ReadInt(isLittleEndian)
+				if err != nil {
+					return nil, err
+				}
+				pathID = int64(pathID32)
+			}
+
+			adds = append(adds, ObjectAdd{
+				FileIndex: fileIndex,
+				PathID:    pathID,
+			})
+		}
+		serializedFile.Adds = adds
+	}
+
+	numRefs, err := dataReader.ReadUint(isLittleEndian)
+	if err != nil {
+		return nil, err
+	}
+
+	externals := []*AssetRef{}
+	for i := 0; i < int(numRefs); i++ {
+		assetRef, err := ParseAssetRef(dataReader, format, isLittleEndian)
+		if err != nil {
+			return nil, err
+		}
+		externals = append(externals, assetRef)
+	}
+	serializedFile.Externals = externals
+
+	if format >= 20 {
+		err = typeMetadata.parseRefTypes(dataReader, format, isLittleEndian)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if format >= 5 {
+		userInformation, err := dataReader.ReadStringNull(256)
+		if err != nil {
+			return nil, err
+		}
+		serializedFile.UserInformation = userInformation
+	}
+
+	return &serializedFile, nil
+}
+
+// indexObjects PathIDからObjectInfoを引く索引を作成
+func (f *SerializedFile) indexObjects() {
+	f.objectIndex = map[int64]*ObjectInfo{}
+	for _, obj := range f.Objects {
+		f.objectIndex[obj.PathID] = obj
+	}
+}
+
+// Object PathIDに対応するObjectInfoを返す
+func (f *SerializedFile) Object(pathID int64) (*ObjectInfo, bool) {
+	if f.objectIndex == nil {
+		f.indexObjects()
+	}
+	obj, ok := f.objectIndex[pathID]
+	return obj, ok
+}
+
+// ParseSerializedFile ノードをシリアライズファイルとしてパース
+// Bundle内の.resS / .resourceノードはリソースとして登録する
+func (b *Bundle) ParseSerializedFile(node FSNode) (*SerializedFile, error) {
+	data, err := b.ReadNode(node)
+	if err != nil {
+		return nil, err
+	}
+
+	serializedFile, err := ParseSerializedFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, n := range b.Nodes {
+		if isResourceName(n.Name) {
+			serializedFile.RegisterResource(n.Name, b.NodeReader(n))
+		}
+	}
+	return serializedFile, nil
+}
@@ -0,0 +1,404 @@
+package unity
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testSerializedFile(format uint32, isLittleEndian bool) *SerializedFile {
+	var order binary.ByteOrder = binary.BigEndian
+	if isLittleEndian {
+		order = binary.LittleEndian
+	}
+
+	typeTree := *testTypeTree()
+	typeTree.ClassID = 1001
+	hash := bytes.Repeat([]byte{0xab}, 16)
+
+	f := &SerializedFile{
+		Format:         format,
+		IsLittleEndian: isLittleEndian,
+		TypeMetadata: &TypeMetadata{
+			PlayerVersion:  "2020.3.1f1",
+			TargetPlatform: 19,
+			HasTypeTrees:   true,
+			Hashes:         []TypeMetadataHash{{ClassID: 1001, ScriptTypeIndex: -1, Hash: hash}},
+			TypeTrees:      []TypeTree{typeTree},
+		},
+		Objects: []*ObjectInfo{
+			{PathID: 1, TypeID: 0, ClassID: 1001, ScriptTypeIndex: -1},
+			{PathID: 2, TypeID: 0, ClassID: 1001, ScriptTypeIndex: -1},
+		},
+		Externals: []*AssetRef{
+			{AssetPath: "", GUID: bytes.Repeat([]byte{1}, 16), Type: 0, FilePath: "library/unity default resources"},
+		},
+		UserInformation: "",
+	}
+	if format < 16 {
+		f.Objects[0].TypeID = 1001
+		f.Objects[1].TypeID = 1001
+	}
+	if format >= 11 {
+		f.Adds = []ObjectAdd{{FileIndex: 1, PathID: 10}}
+	}
+	if format < 13 {
+		// format 12以前はハッシュを持たない
+		f.TypeMetadata.Hashes[0].Hash = nil
+	}
+	if format >= 21 {
+		f.TypeMetadata.Hashes[0].TypeDependencies = []int32{}
+	}
+	if format >= 20 {
+		f.TypeMetadata.RefTypes = []TypeMetadataHash{}
+		f.TypeMetadata.RefTypeTrees = []TypeTree{}
+	}
+
+	data := testObjectBytes(order)
+	f.objectData = map[int64][]byte{1: data, 2: data[:len(data)-4]}
+	f.Objects[0].Size = uint32(len(data))
+	f.Objects[1].Size = uint32(len(data) - 4)
+	f.indexObjects()
+	return f
+}
+
+// testSerializedFile22 2022.3のTextAssetを2つ持つformat 22のシリアライズファイルをUnityのレイアウトどおりに組み立てる
+// 64bitのヘッダ、オブジェクト一覧の境界、データ領域の16バイト境界、オブジェクトごとの8バイト境界を含む
+func testSerializedFile22() []byte {
+	hash := bytes.Repeat([]byte{0x3c}, 16)
+
+	meta := &fixtureBuffer{order: binary.LittleEndian}
+	meta.put("2022.3.5f1", uint32(19), uint8(1))
+
+	// 型: TextAsset
+	meta.put(int32(1))
+	meta.put(int32(49), uint8(0), int16(-1), hash)
+	localStrings := "TextAsset\x00m_Script\x00"
+	meta.put(uint32(9), uint32(len(localStrings)))
+	meta.node32(1, 0, 0, 0, common(55), -1, 0, 0x8000, 0)            // TextAsset Base
+	meta.node32(1, 1, 0, common(840), common(427), -1, 1, 0x8000, 0) // string m_Name
+	meta.node32(1, 2, 1, common(49), common(49), -1, 2, 0x4001, 0)   // Array Array
+	meta.node32(1, 3, 0, common(222), common(795), 4, 3, 0x1, 0)     // int size
+	meta.node32(1, 3, 0, common(81), common(106), 1, 4, 0x1, 0)      // char data
+	meta.node32(1, 1, 0, common(840), 10, -1, 5, 0x8000, 0)          // string m_Script
+	meta.node32(1, 2, 1, common(49), common(49), -1, 6, 0x4001, 0)   // Array Array
+	meta.node32(1, 3, 0, common(222), common(795), 4, 7, 0x1, 0)     // int size
+	meta.node32(1, 3, 0, common(81), common(106), 1, 8, 0x1, 0)      // char data
+	meta.put([]byte(localStrings))
+	meta.put(int32(0)) // 依存する参照型
+
+	// オブジェクト: 1つ目は20バイトのため、2つ目は8バイト境界の24から
+	meta.put(int32(2))
+	meta.alignFrom(48)
+	meta.put(int64(1), int64(0), uint32(20), int32(0))
+	meta.put(int64(2), int64(24), uint32(16), int32(0))
+
+	// スクリプト参照、外部参照、参照型、ユーザー情報
+	meta.put(int32(0), int32(0), int32(0), "")
+
+	data := &fixtureBuffer{order: binary.LittleEndian}
+	data.put(int32(6), []byte("abcdef"), []byte{0, 0})   // m_Name
+	data.put(int32(1), []byte("x"), []byte{0, 0, 0})     // m_Script
+	data.put([]byte{0, 0, 0, 0})                         // オブジェクト間の境界
+	data.put(int32(0))                                   // m_Name
+	data.put(int32(5), []byte("hello"), []byte{0, 0, 0}) // m_Script
+
+	dataOffset := int(alignOffset(int64(48+meta.Len()), 16))
+	header := &fixtureBuffer{order: binary.BigEndian}
+	header.put(uint32(0), uint32(0), uint32(22), uint32(0), []byte{0, 0, 0, 0})
+	header.put(uint32(meta.Len()), int64(dataOffset+data.Len()), int64(dataOffset), int64(0))
+
+	b := make([]byte, dataOffset, dataOffset+data.Len())
+	copy(b, header.Bytes())
+	copy(b[48:], meta.Bytes())
+	return append(b, data.Bytes()...)
+}
+
+func TestSerializedFileFormat22Fixture(t *testing.T) {
+	b := testSerializedFile22()
+	f, err := ParseSerializedFile(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Format != 22 || f.DataOffset != int64(len(b)-40) || f.FileSize != int64(len(b)) || f.DataOffset%16 != 0 {
+		t.Fatalf("unexpected header: %+v", f)
+	}
+	if len(f.Objects) != 2 || f.Objects[0].DataOffset != 0 || f.Objects[0].Size != 20 || f.Objects[1].DataOffset != 24 || f.Objects[1].Size != 16 {
+		t.Fatalf("unexpected objects: %+v %+v", f.Objects[0], f.Objects[1])
+	}
+
+	for pathID, want := range map[int64][2]string{1: {"abcdef", "x"}, 2: {"", "hello"}} {
+		value, err := f.ReadObjectValue(pathID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name, _ := value.Field("m_Name")
+		script, _ := value.Field("m_Script")
+		if name.Value != want[0] || script.Value != want[1] {
+			t.Fatalf("%d: unexpected values: %v %v", pathID, name.Value, script.Value)
+		}
+	}
+
+	// 書き出したバイト列はUnityのレイアウトと一致する
+	var buf bytes.Buffer
+	_, err = f.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Fatalf("WriteTo output differs from the fixture:\n got %x\nwant %x", buf.Bytes(), b)
+	}
+}
+
+func TestSerializedFileWriteRoundTrip(t *testing.T) {
+	for _, format := range []uint32{8, 9, 12, 15, 17, 21, 22} {
+		for _, isLittleEndian := range []bool{true, false} {
+			f := testSerializedFile(format, isLittleEndian)
+
+			var buf bytes.Buffer
+			_, err := f.WriteTo(&buf)
+			if err != nil {
+				t.Fatalf("format %d: %v", format, err)
+			}
+
+			parsed, err := ParseSerializedFile(buf.Bytes())
+			if err != nil {
+				t.Fatalf("format %d: %v", format, err)
+			}
+			if !reflect.DeepEqual(parsed, f) {
+				t.Fatalf("format %d little endian %v: re-parsed model differs\n got %+v\nwant %+v", format, isLittleEndian, parsed, f)
+			}
+
+			value, err := parsed.ReadObjectValue(1)
+			if err != nil {
+				t.Fatalf("format %d: %v", format, err)
+			}
+			name, _ := value.Field("m_Name")
+			if name.Value != "hello" {
+				t.Fatalf("format %d: unexpected m_Name %v", format, name.Value)
+			}
+
+			var rewritten bytes.Buffer
+			_, err = parsed.WriteTo(&rewritten)
+			if err != nil {
+				t.Fatalf("format %d: %v", format, err)
+			}
+			if !bytes.Equal(rewritten.Bytes(), buf.Bytes()) {
+				t.Fatalf("format %d: rewritten file differs", format)
+			}
+		}
+	}
+}
+
+func TestSerializedFileSetObjectData(t *testing.T) {
+	f := testSerializedFile(22, true)
+	_, err := f.WriteTo(&bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.ReadObjectValue(2)
+	if err == nil {
+		t.Fatal("truncated object should fail to decode")
+	}
+
+	value, err := f.ReadObjectValue(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, _ := value.Field("m_Name")
+	name.Value = "patched"
+
+	typeTree, err := f.TypeTree(f.Objects[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := WriteObjectValue(typeTree, value, f.IsLittleEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.SetObjectData(2, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	_, err = f.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseSerializedFile(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err = parsed.ReadObjectValue(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, _ = value.Field("m_Name")
+	if name.Value != "patched" {
+		t.Fatalf("unexpected m_Name %v", name.Value)
+	}
+
+	if f.SetObjectData(99, data) != ErrObjectNotFound {
+		t.Fatal("expected ErrObjectNotFound")
+	}
+}
+
+func TestOpenSerializedFile(t *testing.T) {
+	dir := t.TempDir()
+
+	var buf bytes.Buffer
+	_, err := testSerializedFile(22, true).WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "sharedassets0.assets"), buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "sharedassets0.assets.resS"), []byte("0123456789"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "sharedassets0.resource"), []byte("abcdefghij"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenSerializedFile(filepath.Join(dir, "sharedassets0.assets"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Objects) != 2 {
+		t.Fatalf("unexpected number of objects: %d", len(f.Objects))
+	}
+
+	b, err := f.ReadStreamedData("sharedassets0.assets.resS", 2, 3)
+	if err != nil || string(b) != "234" {
+		t.Fatalf("unexpected streamed data: %q, %v", b, err)
+	}
+
+	streamedResource := &ObjectValue{Kind: ValueKindStruct, Fields: []*ObjectValue{
+		{Name: "m_Source", Value: "archive:/sharedassets0.resource"},
+		{Name: "m_Offset", Value: uint64(5)},
+		{Name: "m_Size", Value: uint64(5)},
+	}}
+	b, err = f.ReadStreamingInfo(streamedResource)
+	if err != nil || string(b) != "fghij" {
+		t.Fatalf("unexpected streamed data: %q, %v", b, err)
+	}
+
+	_, err = f.ReadStreamedData("sharedassets0.assets.resS", 8, 3)
+	if err != ErrInvalidResourceRange {
+		t.Fatalf("expected ErrInvalidResourceRange, got %v", err)
+	}
+	_, err = f.ReadStreamedData("level0.resS", 0, 1)
+	if err != ErrResourceNotFound {
+		t.Fatalf("expected ErrResourceNotFound, got %v", err)
+	}
+}
+
+func TestWalkSerializedFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	var serialized bytes.Buffer
+	_, err := testSerializedFile(22, true).WriteTo(&serialized)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bundle bytes.Buffer
+	err = WriteBundle(&bundle, []BundleNodeData{
+		{Name: "CAB-0123", Status: 4, Data: serialized.Bytes()},
+		{Name: "CAB-0123.resS", Data: []byte("0123456789")},
+		{Name: "CAB-broken", Status: 4, Data: []byte("not a serialized file")},
+	}, &BundleWriterOptions{CompressionType: CompressionTypeLZ4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundlePath := filepath.Join(dir, "bundle.unity3d")
+	serializedPath := filepath.Join(dir, "level0")
+	err = os.WriteFile(bundlePath, bundle.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(serializedPath, serialized.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	walked := []string{}
+	err = WalkSerializedFiles(bundlePath, func(name string, f *SerializedFile, err error) error {
+		if err != nil {
+			walked = append(walked, name+": error")
+			return nil
+		}
+		walked = append(walked, name)
+
+		// リソースはfnの中で読み込める
+		b, err := f.ReadStreamedData("archive:/CAB-0123/CAB-0123.resS", 2, 3)
+		if err != nil || string(b) != "234" {
+			t.Fatalf("unexpected streamed data: %q, %v", b, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(walked, []string{"CAB-0123", "CAB-broken: error"}) {
+		t.Fatalf("unexpected nodes: %q", walked)
+	}
+
+	walked = []string{}
+	err = WalkSerializedFiles(serializedPath, func(name string, f *SerializedFile, err error) error {
+		if err != nil {
+			return err
+		}
+		walked = append(walked, name)
+		if len(f.Objects) != 2 {
+			t.Fatalf("unexpected number of objects: %d", len(f.Objects))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(walked, []string{""}) {
+		t.Fatalf("unexpected files: %q", walked)
+	}
+}
+
+func TestWebDataParseSerializedFile(t *testing.T) {
+	var serialized bytes.Buffer
+	_, err := testSerializedFile(22, true).WriteTo(&serialized)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webData, err := ParseWebData(testWebData(
+		[]string{"sharedassets0.assets", "sharedassets0.assets.resS"},
+		[][]byte{serialized.Bytes(), []byte("0123456789")},
+		nil,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := webData.ParseSerializedFile("sharedassets0.assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Objects) != 2 {
+		t.Fatalf("unexpected number of objects: %d", len(f.Objects))
+	}
+	streamed, err := f.ReadStreamedData("archive:/sharedassets0.assets.resS", 4, 3)
+	if err != nil || string(streamed) != "456" {
+		t.Fatalf("unexpected streamed data: %q, %v", streamed, err)
+	}
+}
@@ -0,0 +1,759 @@
+package unity
+
+import (
+	"bytes"
+	"io"
+	"math"
+)
+
+// serializedFileDataAlignment オブジェクトデータ領域の開始位置の境界
+const serializedFileDataAlignment = 16
+
+// serializedFileObjectAlignment 各オブジェクトのデータの境界
+const serializedFileObjectAlignment = 8
+
+// WriteTo implements the io.WriterTo interface.
+// オブジェクトのオフセットとヘッダのサイズを再計算して書き出し、書き出した内容をモデルに反映する
+func (f *SerializedFile) WriteTo(w io.Writer) (int64, error) {
+	b, err := f.serialize()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// serializedFileHeaderSize ヘッダのサイズ
+// format 8以前はエンディアンがメタデータ側にあるため16バイト
+func serializedFileHeaderSize(format uint32) int {
+	switch {
+	case format >= 22:
+		return 48
+	case format >= 9:
+		return 20
+	}
+	return 16
+}
+
+func (f *SerializedFile) serialize() ([]byte, error) {
+	format := f.Format
+	isLittleEndian := f.IsLittleEndian
+	var endianness uint8
+	if !isLittleEndian {
+		endianness = 1
+	}
+
+	objectData := make([][]byte, len(f.Objects))
+	objectOffsets := make([]int64, len(f.Objects))
+	var dataSize int64
+	for i, obj := range f.Objects {
+		data, err := f.ObjectData(obj)
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(data)) > math.MaxUint32 {
+			return nil, ErrInvalidObjectRange
+		}
+		dataSize = alignOffset(dataSize, serializedFileObjectAlignment)
+		objectOffsets[i] = dataSize
+		objectData[i] = data
+		dataSize += int64(len(data))
+	}
+
+	headerSize := serializedFileHeaderSize(format)
+	dataWriter := NewDataWriter()
+	var metadataSize, dataOffset int64
+
+	if format >= 9 {
+		// ヘッダはサイズ確定後に書き込む
+		err := dataWriter.WriteBytes(make([]byte, headerSize))
+		if err != nil {
+			return nil, err
+		}
+
+		err = f.writeMetadata(dataWriter, objectOffsets)
+		if err != nil {
+			return nil, err
+		}
+		metadataSize = int64(dataWriter.Len() - headerSize)
+
+		err = dataWriter.AlignTo(serializedFileDataAlignment)
+		if err != nil {
+			return nil, err
+		}
+		dataOffset = int64(dataWriter.Len())
+
+		err = writeObjectData(dataWriter, objectData)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// format 8以前はオブジェクトデータの後にエンディアンとメタデータが続く
+		err := dataWriter.WriteBytes(make([]byte, headerSize))
+		if err != nil {
+			return nil, err
+		}
+
+		err = dataWriter.AlignTo(serializedFileDataAlignment)
+		if err != nil {
+			return nil, err
+		}
+		dataOffset = int64(dataWriter.Len())
+
+		err = writeObjectData(dataWriter, objectData)
+		if err != nil {
+			return nil, err
+		}
+
+		metadataStart := dataWriter.Len()
+		err = dataWriter.WriteByte(endianness)
+		if err != nil {
+			return nil, err
+		}
+
+		err = f.writeMetadata(dataWriter, objectOffsets)
+		if err != nil {
+			return nil, err
+		}
+		metadataSize = int64(dataWriter.Len() - metadataStart)
+	}
+	fileSize := int64(dataWriter.Len())
+
+	header, err := writeSerializedFileHeader(format, endianness, metadataSize, fileSize, dataOffset)
+	if err != nil {
+		return nil, err
+	}
+	b := dataWriter.Bytes()
+	copy(b, header)
+
+	f.MetadataSize = uint32(metadataSize)
+	f.FileSize = fileSize
+	f.DataOffset = dataOffset
+	f.Endianness = endianness
+	for i, obj := range f.Objects {
+		obj.DataOffset = objectOffsets[i]
+		obj.Size = uint32(len(objectData[i]))
+	}
+	f.data = b
+	f.objectData = nil
+
+	return b, nil
+}
+
+// writeSerializedFileHeader ヘッダを書き出す
+// format 22以降は旧ヘッダの各サイズを0とし、続く64bitのフィールドに書き込む
+func writeSerializedFileHeader(format uint32, endianness uint8, metadataSize, fileSize, dataOffset int64) ([]byte, error) {
+	if metadataSize > math.MaxUint32 {
+		return nil, ErrSerializedFileTooLarge
+	}
+
+	dataWriter := NewDataWriter()
+	if format >= 22 {
+		for _, v := range []uint32{0, 0, format, 0} {
+			err := dataWriter.WriteUint(v, false)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		err := dataWriter.WriteBytes([]byte{endianness, 0, 0, 0})
+		if err != nil {
+			return nil, err
+		}
+
+		err = dataWriter.WriteUint(uint32(metadataSize), false)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range []int64{fileSize, dataOffset, 0} {
+			err = dataWriter.WriteLong(v, false)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return dataWriter.Bytes(), nil
+	}
+
+	if fileSize > math.MaxUint32 || dataOffset > math.MaxUint32 {
+		return nil, ErrSerializedFileTooLarge
+	}
+
+	for _, v := range []uint32{uint32(metadataSize), uint32(fileSize), format, uint32(dataOffset)} {
+		err := dataWriter.WriteUint(v, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if format >= 9 {
+		err := dataWriter.WriteBytes([]byte{endianness, 0, 0, 0})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dataWriter.Bytes(), nil
+}
+
+// writeObjectData オブジェクトのデータを境界を揃えて書き出す
+func writeObjectData(dataWriter *DataWriter, objectData [][]byte) error {
+	start := dataWriter.Len()
+	for _, data := range objectData {
+		pad := int(alignOffset(int64(dataWriter.Len()-start), serializedFileObjectAlignment)) - (dataWriter.Len() - start)
+		err := dataWriter.WriteBytes(make([]byte, pad))
+		if err != nil {
+			return err
+		}
+
+		err = dataWriter.WriteBytes(data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeMetadata 型情報、オブジェクトテーブル、参照、ユーザー情報を書き出す
+func (f *SerializedFile) writeMetadata(dataWriter *DataWriter, objectOffsets []int64) error {
+	format := f.Format
+	isLittleEndian := f.IsLittleEndian
+
+	typeMetadata := f.TypeMetadata
+	if typeMetadata == nil {
+		typeMetadata = &TypeMetadata{}
+	}
+
+	err := typeMetadata.write(dataWriter, format, isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	if format >= 7 && format <= 13 {
+		var longObjectIDsFlag uint32
+		if f.IsLongObjectIDs {
+			longObjectIDsFlag = 1
+		}
+		err = dataWriter.WriteUint(longObjectIDsFlag, isLittleEndian)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = dataWriter.WriteUint(uint32(len(f.Objects)), isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	for i, obj := range f.Objects {
+		err = writeObjectInfo(dataWriter, obj, objectOffsets[i], format, f.IsLongObjectIDs, isLittleEndian)
+		if err != nil {
+			return err
+		}
+	}
+
+	if format >= 11 {
+		err = dataWriter.WriteUint(uint32(len(f.Adds)), isLittleEndian)
+		if err != nil {
+			return err
+		}
+
+		for _, add := range f.Adds {
+			err = dataWriter.WriteInt(add.FileIndex, isLittleEndian)
+			if err != nil {
+				return err
+			}
+
+			if format >= 14 {
+				err = dataWriter.Align()
+				if err != nil {
+					return err
+				}
+
+				err = dataWriter.WriteLong(add.PathID, isLittleEndian)
+			} else {
+				err = dataWriter.WriteInt(int32(add.PathID), isLittleEndian)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = dataWriter.WriteUint(uint32(len(f.Externals)), isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	for _, assetRef := range f.Externals {
+		err = writeAssetRef(dataWriter, assetRef, format, isLittleEndian)
+		if err != nil {
+			return err
+		}
+	}
+
+	if format >= 20 {
+		err = typeMetadata.writeRefTypes(dataWriter, format, isLittleEndian)
+		if err != nil {
+			return err
+		}
+	}
+
+	if format >= 5 {
+		err = dataWriter.WriteStringNull(f.UserInformation)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeObjectInfo オブジェクトテーブルの1エントリを書き出す
+func writeObjectInfo(dataWriter *DataWriter, obj *ObjectInfo, dataOffset int64, format uint32, isLongObjectIDs, isLittleEndian bool) error {
+	var err error
+	if format >= 14 {
+		err = dataWriter.Align()
+		if err != nil {
+			return err
+		}
+	}
+
+	if isLongObjectIDs || format >= 14 {
+		err = dataWriter.WriteLong(obj.PathID, isLittleEndian)
+	} else {
+		err = dataWriter.WriteInt(int32(obj.PathID), isLittleEndian)
+	}
+	if err != nil {
+		return err
+	}
+
+	if format >= 22 {
+		err = dataWriter.WriteLong(dataOffset, isLittleEndian)
+	} else {
+		if dataOffset > math.MaxUint32 {
+			return ErrSerializedFileTooLarge
+		}
+		err = dataWriter.WriteUint(uint32(dataOffset), isLittleEndian)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = dataWriter.WriteUint(obj.Size, isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	err = dataWriter.WriteInt(obj.TypeID, isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	if format < 16 {
+		err = dataWriter.WriteUshort(uint16(obj.ClassID), isLittleEndian)
+		if err != nil {
+			return err
+		}
+	}
+
+	if format < 11 {
+		err = dataWriter.WriteUshort(obj.IsDestroyed, isLittleEndian)
+	} else if format < 17 {
+		err = dataWriter.WriteShort(obj.ScriptTypeIndex, isLittleEndian)
+	}
+	if err != nil {
+		return err
+	}
+
+	if format == 15 || format == 16 {
+		err = dataWriter.WriteUchar(obj.Stripped, isLittleEndian)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeAssetRef 外部ファイルへの参照を書き出す
+func writeAssetRef(dataWriter *DataWriter, assetRef *AssetRef, format uint32, isLittleEndian bool) error {
+	if format >= 6 {
+		err := dataWriter.WriteStringNull(assetRef.AssetPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	if format >= 5 {
+		if len(assetRef.GUID) != 16 {
+			return ErrInvalidGUID
+		}
+		err := dataWriter.WriteBytes(assetRef.GUID)
+		if err != nil {
+			return err
+		}
+
+		err = dataWriter.WriteInt(assetRef.Type, isLittleEndian)
+		if err != nil {
+			return err
+		}
+	}
+
+	return dataWriter.WriteStringNull(assetRef.FilePath)
+}
+
+// write 型情報を書き出す
+func (typeMetadata *TypeMetadata) write(dataWriter *DataWriter, format uint32, isLittleEndian bool) error {
+	if format >= 7 {
+		err := dataWriter.WriteStringNull(typeMetadata.PlayerVersion)
+		if err != nil {
+			return err
+		}
+	}
+
+	if format >= 8 {
+		err := dataWriter.WriteUint(typeMetadata.TargetPlatform, isLittleEndian)
+		if err != nil {
+			return err
+		}
+	}
+
+	if format < 13 {
+		// format 12以前はClassIDとtype treeの組のみ
+		err := dataWriter.WriteInt(int32(len(typeMetadata.TypeTrees)), isLittleEndian)
+		if err != nil {
+			return err
+		}
+
+		for i := range typeMetadata.TypeTrees {
+			typeTree := &typeMetadata.TypeTrees[i]
+			err = dataWriter.WriteInt(int32(typeTree.ClassID), isLittleEndian)
+			if err != nil {
+				return err
+			}
+
+			err = writeTypeTree(dataWriter, typeTree, format, isLittleEndian)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var hasTypeTrees int8
+	if typeMetadata.HasTypeTrees {
+		hasTypeTrees = 1
+	}
+	err := dataWriter.WriteChar(hasTypeTrees, isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	return writeSerializedTypes(dataWriter, typeMetadata.Hashes, typeMetadata.TypeTrees, format, isLittleEndian, typeMetadata.HasTypeTrees, false)
+}
+
+// writeRefTypes format 20以降の参照型一覧を書き出す
+func (typeMetadata *TypeMetadata) writeRefTypes(dataWriter *DataWriter, format uint32, isLittleEndian bool) error {
+	return writeSerializedTypes(dataWriter, typeMetadata.RefTypes, typeMetadata.RefTypeTrees, format, isLittleEndian, typeMetadata.HasTypeTrees, true)
+}
+
+// writeSerializedTypes 型情報の個数と各型情報を書き出す (format 13+)
+func writeSerializedTypes(dataWriter *DataWriter, hashes []TypeMetadataHash, typeTrees []TypeTree, format uint32, isLittleEndian, hasTypeTrees, isRefType bool) error {
+	if hasTypeTrees && len(typeTrees) != len(hashes) {
+		return ErrInvalidTypeTree
+	}
+
+	err := dataWriter.WriteInt(int32(len(hashes)), isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	for i := range hashes {
+		var typeTree *TypeTree
+		if hasTypeTrees {
+			typeTree = &typeTrees[i]
+		}
+
+		err = writeSerializedType(dataWriter, &hashes[i], typeTree, format, isLittleEndian, isRefType)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSerializedType 1つの型情報とtype treeを書き出す (format 13+)。typeTreeがnilの場合は型情報のみ
+func writeSerializedType(dataWriter *DataWriter, typeMetadataHash *TypeMetadataHash, typeTree *TypeTree, format uint32, isLittleEndian, isRefType bool) error {
+	classID := int32(typeMetadataHash.ClassID)
+	err := dataWriter.WriteInt(classID, isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	if format >= 16 {
+		var isStrippedType uint8
+		if typeMetadataHash.IsStrippedType {
+			isStrippedType = 1
+		}
+		err = dataWriter.WriteUchar(isStrippedType, isLittleEndian)
+		if err != nil {
+			return err
+		}
+	}
+
+	scriptTypeIndex := int16(-1)
+	if format >= 17 {
+		scriptTypeIndex = typeMetadataHash.ScriptTypeIndex
+		err = dataWriter.WriteShort(scriptTypeIndex, isLittleEndian)
+		if err != nil {
+			return err
+		}
+	}
+
+	if (isRefType && scriptTypeIndex >= 0) ||
+		(format < 16 && classID < 0) ||
+		(format >= 16 && classID == monoBehaviourClassID) {
+		err = writeHash(dataWriter, typeMetadataHash.ScriptID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writeHash(dataWriter, typeMetadataHash.Hash)
+	if err != nil {
+		return err
+	}
+
+	if typeTree == nil {
+		return nil
+	}
+
+	err = writeTypeTree(dataWriter, typeTree, format, isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	if format >= 21 {
+		if isRefType {
+			for _, s := range []string{typeMetadataHash.ClassName, typeMetadataHash.Namespace, typeMetadataHash.AssemblyName} {
+				err = dataWriter.WriteStringNull(s)
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			err = dataWriter.WriteInt(int32(len(typeMetadataHash.TypeDependencies)), isLittleEndian)
+			if err != nil {
+				return err
+			}
+
+			for _, dependency := range typeMetadataHash.TypeDependencies {
+				err = dataWriter.WriteInt(dependency, isLittleEndian)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// writeHash 16バイトのハッシュを書き出す
+func writeHash(dataWriter *DataWriter, hash []byte) error {
+	if len(hash) != 0x10 {
+		return ErrInvalidHashSize
+	}
+	return dataWriter.WriteBytes(hash)
+}
+
+func writeTypeTree(dataWriter *DataWriter, typeTree *TypeTree, format uint32, isLittleEndian bool) error {
+	if format == 10 || format >= 12 {
+		return writeTypeTree1012(dataWriter, typeTree, format, isLittleEndian)
+	}
+	return writeTypeTreeOld(dataWriter, typeTree, format, isLittleEndian)
+}
+
+// writeTypeTree1012 ノードを平坦に並べたtype treeを書き出す
+// 文字列オフセットが文字列バッファと一致しない場合は文字列バッファを作り直す
+func writeTypeTree1012(dataWriter *DataWriter, typeTree *TypeTree, format uint32, isLittleEndian bool) error {
+	if !typeTreeStringsResolve(typeTree, typeTree.Data) {
+		buildTypeTreeStrings(typeTree)
+	}
+	typeTree.BufferBytes = uint32(len(typeTree.Data))
+
+	nodes := flattenTypeTree(typeTree, 0, nil)
+	err := dataWriter.WriteUint(uint32(len(nodes)), isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	err = dataWriter.WriteUint(typeTree.BufferBytes, isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		tree := node.tree
+		err = dataWriter.WriteShort(int16(tree.Version), isLittleEndian)
+		if err != nil {
+			return err
+		}
+
+		err = dataWriter.WriteUchar(uint8(node.depth), isLittleEndian)
+		if err != nil {
+			return err
+		}
+
+		err = dataWriter.WriteUchar(tree.typeFlags(), isLittleEndian)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range []int32{tree.TypeOffset, tree.NameOffset, tree.Size} {
+			err = dataWriter.WriteInt(v, isLittleEndian)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = dataWriter.WriteUint(uint32(tree.Index), isLittleEndian)
+		if err != nil {
+			return err
+		}
+
+		err = dataWriter.WriteInt(tree.Flags, isLittleEndian)
+		if err != nil {
+			return err
+		}
+
+		if format >= 19 {
+			err = dataWriter.WriteUlong(tree.RefTypeHash, isLittleEndian)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return dataWriter.WriteBytes(typeTree.Data)
+}
+
+// typeTreeStringsResolve 全ノードの文字列オフセットがbuffer上で同じ文字列を指しているかどうか
+func typeTreeStringsResolve(typeTree *TypeTree, buffer []byte) bool {
+	if typeTreeString(buffer, typeTree.TypeOffset) != typeTree.Type ||
+		typeTreeString(buffer, typeTree.NameOffset) != typeTree.Name {
+		return false
+	}
+	for i := range typeTree.Children {
+		if !typeTreeStringsResolve(&typeTree.Children[i], buffer) {
+			return false
+		}
+	}
+	return true
+}
+
+// buildTypeTreeStrings Type / Nameから文字列バッファとオフセットを作り直す
+// 共通文字列テーブルにある文字列はテーブルを参照する
+func buildTypeTreeStrings(typeTree *TypeTree) {
+	var buffer bytes.Buffer
+	offsets := map[string]int32{}
+
+	stringOffset := func(s string) int32 {
+		if offset, ok := commonStringOffset(s); ok {
+			return int32(offset | 0x80000000)
+		}
+		if offset, ok := offsets[s]; ok {
+			return offset
+		}
+		offset := int32(buffer.Len())
+		buffer.WriteString(s)
+		buffer.WriteByte(0)
+		offsets[s] = offset
+		return offset
+	}
+
+	var walk func(tree *TypeTree)
+	walk = func(tree *TypeTree) {
+		tree.TypeOffset = stringOffset(tree.Type)
+		tree.NameOffset = stringOffset(tree.Name)
+		for i := range tree.Children {
+			walk(&tree.Children[i])
+		}
+	}
+	walk(typeTree)
+
+	typeTree.Data = append([]byte{}, buffer.Bytes()...)
+}
+
+// flattenTypeTree 入れ子のノードをdepth付きで先行順に並べる
+func flattenTypeTree(typeTree *TypeTree, depth int, nodes []typeTreeNode) []typeTreeNode {
+	nodes = append(nodes, typeTreeNode{
+		depth: depth,
+		tree:  *typeTree,
+	})
+	for i := range typeTree.Children {
+		nodes = flattenTypeTree(&typeTree.Children[i], depth+1, nodes)
+	}
+	return nodes
+}
+
+// writeTypeTreeOld format 10 / 12未満の再帰的なtype treeを書き出す
+func writeTypeTreeOld(dataWriter *DataWriter, typeTree *TypeTree, format uint32, isLittleEndian bool) error {
+	err := dataWriter.WriteStringNull(typeTree.Type)
+	if err != nil {
+		return err
+	}
+
+	err = dataWriter.WriteStringNull(typeTree.Name)
+	if err != nil {
+		return err
+	}
+
+	err = dataWriter.WriteInt(typeTree.Size, isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	// format 2のみ variableCount を持つ
+	if format == 2 {
+		err = dataWriter.WriteInt(int32(len(flattenTypeTree(typeTree, 0, nil))), isLittleEndian)
+		if err != nil {
+			return err
+		}
+	}
+
+	if format != 3 {
+		err = dataWriter.WriteInt(int32(typeTree.Index), isLittleEndian)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = dataWriter.WriteInt(int32(typeTree.typeFlags()), isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	err = dataWriter.WriteInt(typeTree.Version, isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	if format != 3 {
+		err = dataWriter.WriteInt(typeTree.Flags, isLittleEndian)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = dataWriter.WriteInt(int32(len(typeTree.Children)), isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	for i := range typeTree.Children {
+		err = writeTypeTreeOld(dataWriter, &typeTree.Children[i], format, isLittleEndian)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
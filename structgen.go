@@ -0,0 +1,249 @@
+package unity
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GenerateStructs type treeからGoの構造体の定義とDecodeメソッドのソースを生成する
+// Decodeはtype treeの順にObjectReaderから読み込み、ReadObjectValueと同じ規則でアラインする
+// 同名の型でレイアウトが異なるものは番号を付けた別の型にする
+func GenerateStructs(packageName string, typeTrees []*TypeTree) ([]byte, error) {
+	g := &structGenerator{
+		byName: map[string]*structDef{},
+	}
+	for _, typeTree := range typeTrees {
+		if len(typeTree.Children) == 0 && typeTree.Type == "" {
+			continue
+		}
+		g.addClass(typeTree)
+	}
+	return g.generate(packageName)
+}
+
+// structSignature 同名の型のレイアウトが同じかどうかを比べるための文字列
+func structSignature(node *TypeTree) string {
+	var buf bytes.Buffer
+	var walk func(node *TypeTree)
+	walk = func(node *TypeTree) {
+		fmt.Fprintf(&buf, "%s %s %d %v %x(", node.Type, node.Name, node.Size, node.IsArray, node.Flags)
+		for i := range node.Children {
+			walk(&node.Children[i])
+		}
+		buf.WriteByte(')')
+	}
+	walk(node)
+	return buf.String()
+}
+
+// goIdentifier 任意の文字列をエクスポートされたGoの識別子にする
+func goIdentifier(s string) string {
+	var buf bytes.Buffer
+	upper := true
+	for _, c := range s {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			upper = true
+			continue
+		}
+		if upper {
+			c = unicode.ToUpper(c)
+			upper = false
+		}
+		buf.WriteRune(c)
+	}
+
+	ident := buf.String()
+	if ident == "" || unicode.IsDigit(rune(ident[0])) {
+		ident = "X" + ident
+	}
+	return ident
+}
+
+// typeIdentifier Goの型を識別子の一部として使える文字列にする ([]int32 -> SliceInt32)
+func typeIdentifier(goType string) string {
+	goType = strings.Replace(goType, "[]", "Slice_", -1)
+	goType = strings.Replace(goType, "[", "Array_", -1)
+	return goIdentifier(goType)
+}
+
+type structDef struct {
+	name       string
+	classID    ClassID
+	isClass    bool
+	node       *TypeTree
+	fields     []string
+	fieldNames []string
+	signature  string
+}
+
+type structGenerator struct {
+	structs []*structDef
+	byName  map[string]*structDef
+}
+
+// addClass ルートのtype treeをクラスとして追加する
+func (g *structGenerator) addClass(typeTree *TypeTree) {
+	def := g.addStruct(typeTree, goIdentifier(typeTree.Type))
+	if !def.isClass {
+		def.isClass = true
+		def.classID = typeTree.ClassID
+	}
+}
+
+// addStruct 構造体を追加する。同名でレイアウトが異なる場合は番号を付けた別の型にする
+func (g *structGenerator) addStruct(node *TypeTree, baseName string) *structDef {
+	signature := structSignature(node)
+	name := baseName
+	for i := 2; ; i++ {
+		def, ok := g.byName[name]
+		if !ok {
+			break
+		}
+		if def.signature == signature {
+			return def
+		}
+		name = baseName + strconv.Itoa(i)
+	}
+
+	def := &structDef{
+		name:      name,
+		node:      node,
+		signature: signature,
+	}
+	g.byName[name] = def
+	g.structs = append(g.structs, def)
+
+	used := map[string]bool{"Decode": true}
+	for i := range node.Children {
+		child := &node.Children[i]
+		fieldName := goIdentifier(strings.TrimPrefix(child.Name, "m_"))
+		for j := 2; used[fieldName]; j++ {
+			fieldName = goIdentifier(strings.TrimPrefix(child.Name, "m_")) + strconv.Itoa(j)
+		}
+		used[fieldName] = true
+
+		tag := child.Name
+		if child.alignsAfter() {
+			tag += ",align"
+		}
+		def.fields = append(def.fields, fmt.Sprintf("%s %s `unity:%q`", fieldName, g.goType(child), tag))
+		def.fieldNames = append(def.fieldNames, fieldName)
+	}
+	return def
+}
+
+// goType ノードに対応するGoの型
+func (g *structGenerator) goType(node *TypeTree) string {
+	if primitive, ok := primitiveTypes[node.Type]; ok {
+		return primitive.goType
+	}
+
+	switch {
+	case node.Type == "string":
+		return "string"
+	case node.Type == "TypelessData":
+		return "[]byte"
+	case node.IsArray && len(node.Children) == 2:
+		return "[]" + g.goType(&node.Children[1])
+	}
+
+	if arr, ok := node.arrayNode(); ok {
+		return "[]" + g.goType(&arr.Children[1])
+	}
+
+	if len(node.Children) == 0 {
+		size := node.Size
+		if size < 0 {
+			size = 0
+		}
+		return fmt.Sprintf("[%d]byte", size)
+	}
+
+	name := goIdentifier(node.Type)
+	if node.Type == "pair" && len(node.Children) == 2 {
+		name = "Pair" + typeIdentifier(g.goType(&node.Children[0])) + typeIdentifier(g.goType(&node.Children[1]))
+	}
+	return g.addStruct(node, name).name
+}
+
+// genDecode ノードをtargetに読み込むコードを書き出す (readObjectValueと同じ規則)
+func (g *structGenerator) genDecode(buf *bytes.Buffer, node *TypeTree, target string, depth int) {
+	if primitive, ok := primitiveTypes[node.Type]; ok {
+		fmt.Fprintf(buf, "%s, err = r.%s()\nif err != nil {\nreturn err\n}\n", target, primitive.readMethod)
+	} else if node.Type == "string" {
+		fmt.Fprintf(buf, "%s, err = r.ReadString()\nif err != nil {\nreturn err\n}\n", target)
+	} else if node.Type == "TypelessData" {
+		n := fmt.Sprintf("n%d", depth)
+		fmt.Fprintf(buf, "{\n")
+		genArraySize(buf, n)
+		fmt.Fprintf(buf, "%s, err = r.ReadBytes(int(%s), r.IsLittleEndian)\nif err != nil {\nreturn err\n}\n}\n", target, n)
+	} else if node.IsArray && len(node.Children) == 2 {
+		g.genArray(buf, node, target, depth)
+	} else if arr, ok := node.arrayNode(); ok {
+		g.genArray(buf, arr, target, depth)
+	} else if len(node.Children) == 0 {
+		fmt.Fprintf(buf, "{\nb, err := r.ReadBytes(len(%s), r.IsLittleEndian)\nif err != nil {\nreturn err\n}\ncopy(%s[:], b)\n}\n", target, target)
+	} else {
+		fmt.Fprintf(buf, "err = %s.Decode(r)\nif err != nil {\nreturn err\n}\n", target)
+	}
+
+	if node.alignsAfter() {
+		fmt.Fprintf(buf, "err = r.Align()\nif err != nil {\nreturn err\n}\n")
+	}
+}
+
+// genArraySize 配列の要素数を読み込み範囲を確認するコードを書き出す
+func genArraySize(buf *bytes.Buffer, n string) {
+	fmt.Fprintf(buf, "%s, err := r.ReadInt32()\nif err != nil {\nreturn err\n}\n", n)
+	fmt.Fprintf(buf, "if %s < 0 || int(%s) > r.Len() {\nreturn unity.ErrInvalidObjectRange\n}\n", n, n)
+}
+
+// genArray Arrayノード (size, data) の要素を読み込むコードを書き出す。アラインは呼び出し側で行う
+func (g *structGenerator) genArray(buf *bytes.Buffer, arr *TypeTree, target string, depth int) {
+	n := fmt.Sprintf("n%d", depth)
+	i := fmt.Sprintf("i%d", depth)
+
+	fmt.Fprintf(buf, "{\n")
+	genArraySize(buf, n)
+	fmt.Fprintf(buf, "%s = make(%s, %s)\n", target, "[]"+g.goType(&arr.Children[1]), n)
+	fmt.Fprintf(buf, "for %s := range %s {\n", i, target)
+	g.genDecode(buf, &arr.Children[1], fmt.Sprintf("%s[%s]", target, i), depth+1)
+	fmt.Fprintf(buf, "}\n}\n")
+}
+
+// generate 全ての構造体の定義とDecodeメソッドを書き出す
+func (g *structGenerator) generate(packageName string) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// generated by gen_structs; DO NOT EDIT\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", packageName)
+	fmt.Fprintf(&buf, "import unity \"github.com/PyYoshi/UnityAssets\"\n\n")
+
+	// Decodeの生成中に構造体が増えることはない (goTypeは追加済みの型を返す)
+	for _, def := range g.structs {
+		if def.isClass {
+			fmt.Fprintf(&buf, "// %s ClassID: %d\n", def.name, def.classID)
+		} else {
+			fmt.Fprintf(&buf, "// %s %s\n", def.name, def.node.Type)
+		}
+		fmt.Fprintf(&buf, "type %s struct {\n%s\n}\n\n", def.name, strings.Join(def.fields, "\n"))
+
+		var body bytes.Buffer
+		for i := range def.node.Children {
+			g.genDecode(&body, &def.node.Children[i], "v."+def.fieldNames[i], 0)
+		}
+
+		fmt.Fprintf(&buf, "// Decode type treeの順にフィールドを読み込む\n")
+		fmt.Fprintf(&buf, "func (v *%s) Decode(r *unity.ObjectReader) error {\n", def.name)
+		if body.Len() > 0 {
+			fmt.Fprintf(&buf, "var err error\n")
+			buf.Write(body.Bytes())
+		}
+		fmt.Fprintf(&buf, "return nil\n}\n\n")
+	}
+
+	return format.Source(buf.Bytes())
+}
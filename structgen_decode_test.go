@@ -0,0 +1,44 @@
+package unity_test
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	unity "github.com/PyYoshi/UnityAssets"
+)
+
+func TestGeneratedDecode(t *testing.T) {
+	for _, isLittleEndian := range []bool{true, false} {
+		var order binary.ByteOrder = binary.BigEndian
+		if isLittleEndian {
+			order = binary.LittleEndian
+		}
+
+		dataReader, err := unity.NewDataReader(unity.SampleObjectBytes(order))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var v Test
+		err = v.Decode(&unity.ObjectReader{DataReader: dataReader, IsLittleEndian: isLittleEndian})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dataReader.Len() != 0 {
+			t.Fatalf("%d bytes left unread", dataReader.Len())
+		}
+
+		want := Test{
+			Name:    "hello",
+			Enabled: true,
+			Ints:    []int32{1, -2, 3},
+			Map:     []PairStringInt64{{First: "a", Second: 10}, {First: "bb", Second: -20}},
+			Blob:    []byte{9, 8, 7},
+			Float:   1.5,
+		}
+		if !reflect.DeepEqual(v, want) {
+			t.Fatalf("little endian %v: unexpected value\n got %+v\nwant %+v", isLittleEndian, v, want)
+		}
+	}
+}
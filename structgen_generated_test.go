@@ -0,0 +1,114 @@
+// generated by gen_structs; DO NOT EDIT
+
+package unity_test
+
+import unity "github.com/PyYoshi/UnityAssets"
+
+// Test ClassID: 0
+type Test struct {
+	Name    string            `unity:"m_Name,align"`
+	Enabled bool              `unity:"m_Enabled,align"`
+	Ints    []int32           `unity:"m_Ints"`
+	Map     []PairStringInt64 `unity:"m_Map"`
+	Blob    []byte            `unity:"m_Blob,align"`
+	Float   float32           `unity:"m_Float"`
+}
+
+// Decode type treeの順にフィールドを読み込む
+func (v *Test) Decode(r *unity.ObjectReader) error {
+	var err error
+	v.Name, err = r.ReadString()
+	if err != nil {
+		return err
+	}
+	err = r.Align()
+	if err != nil {
+		return err
+	}
+	v.Enabled, err = r.ReadBool()
+	if err != nil {
+		return err
+	}
+	err = r.Align()
+	if err != nil {
+		return err
+	}
+	{
+		n0, err := r.ReadInt32()
+		if err != nil {
+			return err
+		}
+		if n0 < 0 || int(n0) > r.Len() {
+			return unity.ErrInvalidObjectRange
+		}
+		v.Ints = make([]int32, n0)
+		for i0 := range v.Ints {
+			v.Ints[i0], err = r.ReadInt32()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n0, err := r.ReadInt32()
+		if err != nil {
+			return err
+		}
+		if n0 < 0 || int(n0) > r.Len() {
+			return unity.ErrInvalidObjectRange
+		}
+		v.Map = make([]PairStringInt64, n0)
+		for i0 := range v.Map {
+			err = v.Map[i0].Decode(r)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	{
+		n0, err := r.ReadInt32()
+		if err != nil {
+			return err
+		}
+		if n0 < 0 || int(n0) > r.Len() {
+			return unity.ErrInvalidObjectRange
+		}
+		v.Blob, err = r.ReadBytes(int(n0), r.IsLittleEndian)
+		if err != nil {
+			return err
+		}
+	}
+	err = r.Align()
+	if err != nil {
+		return err
+	}
+	v.Float, err = r.ReadFloat32()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// PairStringInt64 pair
+type PairStringInt64 struct {
+	First  string `unity:"first,align"`
+	Second int64  `unity:"second"`
+}
+
+// Decode type treeの順にフィールドを読み込む
+func (v *PairStringInt64) Decode(r *unity.ObjectReader) error {
+	var err error
+	v.First, err = r.ReadString()
+	if err != nil {
+		return err
+	}
+	err = r.Align()
+	if err != nil {
+		return err
+	}
+	v.Second, err = r.ReadInt64()
+	if err != nil {
+		return err
+	}
+	return nil
+}
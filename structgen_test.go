@@ -0,0 +1,34 @@
+package unity
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+)
+
+var updateGenerated = flag.Bool("update", false, "update structgen_generated_test.go")
+
+// TestGenerateStructs testTypeTreeから生成したソースがstructgen_generated_test.goと一致するか確認する
+// 生成したソースはunity_testパッケージのテストとしてコンパイルされ、TestGeneratedDecodeで使われる
+func TestGenerateStructs(t *testing.T) {
+	src, err := GenerateStructs("unity_test", []*TypeTree{testTypeTree()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *updateGenerated {
+		err = os.WriteFile("structgen_generated_test.go", src, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	golden, err := os.ReadFile("structgen_generated_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, golden) {
+		t.Fatalf("structgen_generated_test.go is out of date (go test -run TestGenerateStructs -update)\n%s", src)
+	}
+}
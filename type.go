@@ -1,23 +1,45 @@
 package unity
 
+import "bytes"
+
+// monoBehaviourClassID format 16以降でスクリプトIDを持つクラス (MonoBehaviour)
+const monoBehaviourClassID = 114
+
+// TypeMetadata シリアライズファイルの型情報
 type TypeMetadata struct {
 	PlayerVersion  string
 	TargetPlatform uint32
+	HasTypeTrees   bool
 	Hashes         []TypeMetadataHash
 	TypeTrees      []TypeTree
+	RefTypes       []TypeMetadataHash
+	RefTypeTrees   []TypeTree
 }
 
+// TypeMetadataHash 型ごとの情報 (SerializedType)
+// ClassName / Namespace / AssemblyName はformat 21以降の参照型 (RefTypes) のみ
 type TypeMetadataHash struct {
-	ClassID ClassID
-	Hash    []byte
+	ClassID          ClassID
+	IsStrippedType   bool
+	ScriptTypeIndex  int16
+	ScriptID         []byte
+	Hash             []byte
+	TypeDependencies []int32
+	ClassName        string
+	Namespace        string
+	AssemblyName     string
 }
 
+// TypeTree オブジェクトのフィールド構造
+// TypeOffset / NameOffset は最上位ビットが立っている場合、共通文字列テーブル上のオフセットを示す
+// TypeFlags はノードのtypeFlagsの値で、IsArrayはそのbit 0
 type TypeTree struct {
 	ClassID     ClassID
 	BufferBytes uint32
 	Data        []byte
 	Version     int32
 	IsArray     bool
+	TypeFlags   uint8
 	TypeOffset  int32
 	Type        string
 	NameOffset  int32
@@ -25,221 +47,380 @@ type TypeTree struct {
 	Size        int32
 	Index       int64
 	Flags       int32
+	RefTypeHash uint64
 	Children    []TypeTree
 }
 
+// typeFlags 書き出しと型ハッシュに使うtypeFlags。TypeFlagsが未設定の場合はIsArrayから求める
+func (t *TypeTree) typeFlags() uint8 {
+	if t.TypeFlags == 0 && t.IsArray {
+		return 1
+	}
+	return t.TypeFlags
+}
+
 func ParseTypeMetadata(dataReader *DataReader, format uint32, isLittleEndian bool) (*TypeMetadata, error) {
 	typeMetadata := TypeMetadata{}
-	typeMetadataPlayerVersion, err := dataReader.ReadStringNull(256)
-	if err != nil {
-		return nil, err
+	if format >= 7 {
+		typeMetadataPlayerVersion, err := dataReader.ReadStringNull(256)
+		if err != nil {
+			return nil, err
+		}
+		typeMetadata.PlayerVersion = typeMetadataPlayerVersion
 	}
-	typeMetadata.PlayerVersion = typeMetadataPlayerVersion
 
-	typeMetadataTargetPlatform, err := dataReader.ReadUint(isLittleEndian)
-	if err != nil {
-		return nil, err
+	if format >= 8 {
+		typeMetadataTargetPlatform, err := dataReader.ReadUint(isLittleEndian)
+		if err != nil {
+			return nil, err
+		}
+		typeMetadata.TargetPlatform = typeMetadataTargetPlatform
 	}
-	typeMetadata.TargetPlatform = typeMetadataTargetPlatform
 
 	if format >= 13 {
 		typeMetadataHasTypeTreesChar, err := dataReader.ReadChar(isLittleEndian)
 		if err != nil {
 			return nil, err
 		}
-		typeMetadataHasTypeTrees := false
 		if typeMetadataHasTypeTreesChar > 0 {
-			typeMetadataHasTypeTrees = true
+			typeMetadata.HasTypeTrees = true
 		}
-		// pp.Println("typeMetadataHasTypeTrees", typeMetadataHasTypeTrees)
 
 		typeMetadataNumTypes, err := dataReader.ReadInt(isLittleEndian)
 		if err != nil {
 			return nil, err
 		}
-		// pp.Println("typeMetadataNumTypes", typeMetadataNumTypes)
 
 		typeMetadataHashes := []TypeMetadataHash{}
 		typeMetadataTypeTrees := []TypeTree{}
 		for i := 0; i < int(typeMetadataNumTypes); i++ {
-			typeMetadataClassID, err := dataReader.ReadInt(isLittleEndian)
+			typeMetadataHash, typeTree, err := parseSerializedType(dataReader, format, isLittleEndian, typeMetadata.HasTypeTrees, false)
 			if err != nil {
 				return nil, err
 			}
-			// pp.Println("typeMetadataClassID", typeMetadataClassID)
-
-			var typeMetadataHash []byte
-			if typeMetadataClassID < 0 {
-				typeMetadataHash, err = dataReader.ReadBytes(0x20, isLittleEndian)
-				if err != nil {
-					return nil, err
-				}
-			} else {
-				typeMetadataHash, err = dataReader.ReadBytes(0x10, isLittleEndian)
-				if err != nil {
-					return nil, err
-				}
-			}
-			typeMetadataHashes = append(typeMetadataHashes, TypeMetadataHash{ClassID(typeMetadataClassID), typeMetadataHash})
+			typeMetadataHashes = append(typeMetadataHashes, *typeMetadataHash)
 
-			if typeMetadataHasTypeTrees {
-				typeTree, err := ParseTypeTree(dataReader, format, isLittleEndian, ClassID(typeMetadataClassID))
-				if err != nil {
-					return nil, err
-				}
+			if typeTree != nil {
 				typeMetadataTypeTrees = append(typeMetadataTypeTrees, *typeTree)
 			}
 		}
 		typeMetadata.Hashes = typeMetadataHashes
 		typeMetadata.TypeTrees = typeMetadataTypeTrees
-		// pp.Println("typeMetadataHashes", typeMetadataHashes)
-		// pp.Println("typeMetadataTypeTrees", typeMetadataTypeTrees)
 	} else {
-		// typeMetadataNumFields, err := dataReader.ReadInt(isLittleEndian)
-		// if err != nil {
-		// 	return nil, err
-		// }
-
-		// typeMetadataTypeTrees := []TypeTree{}
-		// for i := 0; i < int(typeMetadataNumFields); i++ {
-		// 	typeMetadataClassID, err := dataReader.ReadInt(isLittleEndian)
-		// 	if err != nil {
-		// 		return nil, err
-		// 	}
-		// 	typeTree, err := ParseTypeTree(dataReader, format, isLittleEndian, ClassID(typeMetadataClassID))
-		// 	if err != nil {
-		// 		return nil, err
-		// 	}
-		// 	typeMetadataTypeTrees = append(typeMetadataTypeTrees, *typeTree)
-		// }
-		// typeMetadata.TypeTrees = typeMetadataTypeTrees
+		// format 12以前は常にtype treeを持ち、ハッシュを持たない
+		typeMetadata.HasTypeTrees = true
+
+		typeMetadataNumFields, err := dataReader.ReadInt(isLittleEndian)
+		if err != nil {
+			return nil, err
+		}
+
+		typeMetadataHashes := []TypeMetadataHash{}
+		typeMetadataTypeTrees := []TypeTree{}
+		for i := 0; i < int(typeMetadataNumFields); i++ {
+			typeMetadataClassID, err := dataReader.ReadInt(isLittleEndian)
+			if err != nil {
+				return nil, err
+			}
+			typeMetadataHashes = append(typeMetadataHashes, TypeMetadataHash{
+				ClassID:         ClassID(typeMetadataClassID),
+				ScriptTypeIndex: -1,
+			})
+
+			typeTree, err := ParseTypeTree(dataReader, format, isLittleEndian, ClassID(typeMetadataClassID))
+			if err != nil {
+				return nil, err
+			}
+			typeMetadataTypeTrees = append(typeMetadataTypeTrees, *typeTree)
+		}
+		typeMetadata.Hashes = typeMetadataHashes
+		typeMetadata.TypeTrees = typeMetadataTypeTrees
 	}
 	return &typeMetadata, nil
 }
 
-func parseTypeTree1012(dataReader *DataReader, typeTree *TypeTree, isLittleEndian bool) error {
+// parseRefTypes format 20以降の参照型 (SerializeReference) 一覧をパース
+func (typeMetadata *TypeMetadata) parseRefTypes(dataReader *DataReader, format uint32, isLittleEndian bool) error {
+	numRefTypes, err := dataReader.ReadInt(isLittleEndian)
+	if err != nil {
+		return err
+	}
+
+	refTypes := []TypeMetadataHash{}
+	refTypeTrees := []TypeTree{}
+	for i := 0; i < int(numRefTypes); i++ {
+		refType, typeTree, err := parseSerializedType(dataReader, format, isLittleEndian, typeMetadata.HasTypeTrees, true)
+		if err != nil {
+			return err
+		}
+		refTypes = append(refTypes, *refType)
+
+		if typeTree != nil {
+			refTypeTrees = append(refTypeTrees, *typeTree)
+		}
+	}
+	typeMetadata.RefTypes = refTypes
+	typeMetadata.RefTypeTrees = refTypeTrees
+
+	return nil
+}
+
+// parseSerializedType 1つの型情報とtype treeをパース (format 13+)
+func parseSerializedType(dataReader *DataReader, format uint32, isLittleEndian, hasTypeTrees, isRefType bool) (*TypeMetadataHash, *TypeTree, error) {
+	typeMetadataHash := TypeMetadataHash{
+		ScriptTypeIndex: -1,
+	}
+
+	classID, err := dataReader.ReadInt(isLittleEndian)
+	if err != nil {
+		return nil, nil, err
+	}
+	typeMetadataHash.ClassID = ClassID(classID)
+
+	if format >= 16 {
+		isStrippedType, err := dataReader.ReadUchar(isLittleEndian)
+		if err != nil {
+			return nil, nil, err
+		}
+		if isStrippedType > 0 {
+			typeMetadataHash.IsStrippedType = true
+		}
+	}
+
+	if format >= 17 {
+		scriptTypeIndex, err := dataReader.ReadShort(isLittleEndian)
+		if err != nil {
+			return nil, nil, err
+		}
+		typeMetadataHash.ScriptTypeIndex = scriptTypeIndex
+	}
+
+	if (isRefType && typeMetadataHash.ScriptTypeIndex >= 0) ||
+		(format < 16 && classID < 0) ||
+		(format >= 16 && classID == monoBehaviourClassID) {
+		scriptID, err := dataReader.ReadBytes(0x10, isLittleEndian)
+		if err != nil {
+			return nil, nil, err
+		}
+		typeMetadataHash.ScriptID = scriptID
+	}
+
+	hash, err := dataReader.ReadBytes(0x10, isLittleEndian)
+	if err != nil {
+		return nil, nil, err
+	}
+	typeMetadataHash.Hash = hash
+
+	if !hasTypeTrees {
+		return &typeMetadataHash, nil, nil
+	}
+
+	typeTree, err := ParseTypeTree(dataReader, format, isLittleEndian, ClassID(classID))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if format >= 21 {
+		if isRefType {
+			className, err := dataReader.ReadStringNull(256)
+			if err != nil {
+				return nil, nil, err
+			}
+			typeMetadataHash.ClassName = className
+
+			namespace, err := dataReader.ReadStringNull(256)
+			if err != nil {
+				return nil, nil, err
+			}
+			typeMetadataHash.Namespace = namespace
+
+			assemblyName, err := dataReader.ReadStringNull(256)
+			if err != nil {
+				return nil, nil, err
+			}
+			typeMetadataHash.AssemblyName = assemblyName
+		} else {
+			numDependencies, err := dataReader.ReadInt(isLittleEndian)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			dependencies := []int32{}
+			for i := 0; i < int(numDependencies); i++ {
+				dependency, err := dataReader.ReadInt(isLittleEndian)
+				if err != nil {
+					return nil, nil, err
+				}
+				dependencies = append(dependencies, dependency)
+			}
+			typeMetadataHash.TypeDependencies = dependencies
+		}
+	}
+
+	return &typeMetadataHash, typeTree, nil
+}
+
+// typeTreeNode type treeのバイナリ上で平坦に並んでいるノード
+type typeTreeNode struct {
+	depth int
+	tree  TypeTree
+}
+
+// typeTreeString オフセットから文字列を解決する
+// 最上位ビットが立っている場合は共通文字列テーブル、それ以外はローカルの文字列バッファを参照する
+func typeTreeString(buffer []byte, offset int32) string {
+	if offset < 0 {
+		s, _ := commonString(uint32(offset) & 0x7fffffff)
+		return s
+	}
+
+	if int(offset) >= len(buffer) {
+		return ""
+	}
+	b := buffer[offset:]
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// nestTypeTreeNodes depthに従いnodes[i]以下のノードを入れ子にする
+func nestTypeTreeNodes(nodes []typeTreeNode, i int) (TypeTree, int) {
+	curr := nodes[i].tree
+	j := i + 1
+	for j < len(nodes) && nodes[j].depth > nodes[i].depth {
+		var child TypeTree
+		child, j = nestTypeTreeNodes(nodes, j)
+		curr.Children = append(curr.Children, child)
+	}
+	return curr, j
+}
+
+func parseTypeTree1012(dataReader *DataReader, typeTree *TypeTree, format uint32, isLittleEndian bool) error {
 	typeTreeNumNodes, err := dataReader.ReadUint(isLittleEndian)
 	if err != nil {
 		return err
 	}
-	// pp.Println("typeTreeNumNodes", typeTreeNumNodes)
 
 	typeTreeBufferBytes, err := dataReader.ReadUint(isLittleEndian)
 	if err != nil {
 		return err
 	}
 	typeTree.BufferBytes = typeTreeBufferBytes
-	// pp.Println("typeTreeBufferBytes", typeTreeBufferBytes)
 
-	typeTreeNodeData, err := dataReader.ReadBytes(int(24*typeTreeNumNodes), isLittleEndian)
+	// format 19以降はRefTypeHashが追加され1ノード32バイト
+	nodeSize := 24
+	if format >= 19 {
+		nodeSize = 32
+	}
+
+	// ノード数とバッファのサイズはファイルの値のため、掛け算や確保の前に残りのバイト数と比べる
+	remaining := uint64(dataReader.Len())
+	if uint64(typeTreeNumNodes) > remaining/uint64(nodeSize) || uint64(typeTreeBufferBytes) > remaining-uint64(typeTreeNumNodes)*uint64(nodeSize) {
+		return ErrInvalidTypeTree
+	}
+
+	typeTreeNodeData, err := dataReader.ReadBytes(nodeSize*int(typeTreeNumNodes), isLittleEndian)
 	if err != nil {
 		return err
 	}
-	// pp.Println("typeTreeNodeData", typeTreeNodeData)
-	// pp.Println("typeTreeNodeData length", len(typeTreeNodeData))
 
 	typeTreeData, err := dataReader.ReadBytes(int(typeTree.BufferBytes), isLittleEndian)
 	if err != nil {
 		return err
 	}
 	typeTree.Data = typeTreeData
-	// pp.Println("typeTreeData", string(typeTreeData))
-
-	// parents := []TypeTree{*typeTree}
-	// return nil
 
 	typeTreeDataReader, err := NewDataReader(typeTreeNodeData)
 	if err != nil {
 		return err
 	}
 
+	nodes := []typeTreeNode{}
 	for i := uint32(0); i < typeTreeNumNodes; i++ {
+		typeTreeCurr := TypeTree{}
+
 		typeTreeVersion, err := typeTreeDataReader.ReadShort(isLittleEndian)
 		if err != nil {
 			return err
 		}
-		// pp.Println("typeTreeVersion", typeTreeVersion)
+		typeTreeCurr.Version = int32(typeTreeVersion)
 
-		typeTreeDepth, err := typeTreeDataReader.ReadChar(isLittleEndian)
+		typeTreeDepth, err := typeTreeDataReader.ReadUchar(isLittleEndian)
 		if err != nil {
 			return err
 		}
-		// pp.Println("typeTreeDepth", typeTreeDepth)
 
-		var typeTreeCurr TypeTree
-		if typeTreeDepth == 0 {
-			typeTreeCurr = *typeTree
-		} else {
-			// numParents := len(parents)
-			// for {
-			// 	if len(parents) > int(typeTreeDepth) {
-			// 		break
-			// 	}
-			// 	// parents = parents[:len(parents)-1]
-			// 	// parents.append(curr)
-			// }
-		}
-
-		typeTreeCurr.Version = int32(typeTreeVersion)
-		typeTreeCurrIsArrayBytes, err := typeTreeDataReader.ReadChar(isLittleEndian)
+		// bit 0が配列、それ以外のビットはSerializeReference等で使われるため値をそのまま持つ
+		typeTreeCurrTypeFlags, err := typeTreeDataReader.ReadUchar(isLittleEndian)
 		if err != nil {
 			return err
 		}
-		typeTreeCurrIsArray := false
-		if typeTreeCurrIsArrayBytes > 0 {
-			typeTreeCurrIsArray = true
-		}
-		typeTreeCurr.IsArray = typeTreeCurrIsArray
-		// pp.Println("typeTreeCurrIsArray", typeTreeCurrIsArray)
+		typeTreeCurr.TypeFlags = typeTreeCurrTypeFlags
+		typeTreeCurr.IsArray = typeTreeCurrTypeFlags&1 != 0
 
+		// 最上位ビットは共通文字列テーブルを参照することを示す
 		typeTreeCurrTypeOffset, err := typeTreeDataReader.ReadInt(isLittleEndian)
 		if err != nil {
 			return err
 		}
-		if typeTreeCurrTypeOffset < 0 {
-			typeTreeCurrTypeOffset = typeTreeCurrTypeOffset & 0x7fffffff
-		}
 		typeTreeCurr.TypeOffset = typeTreeCurrTypeOffset
-		// pp.Println("typeTreeCurrTypeOffset", typeTreeCurrTypeOffset)
+		typeTreeCurr.Type = typeTreeString(typeTreeData, typeTreeCurrTypeOffset)
 
 		typeTreeCurrNameOffset, err := typeTreeDataReader.ReadInt(isLittleEndian)
 		if err != nil {
 			return err
 		}
-		if typeTreeCurrNameOffset < 0 {
-			typeTreeCurrNameOffset = typeTreeCurrNameOffset & 0x7fffffff
-		}
 		typeTreeCurr.NameOffset = typeTreeCurrNameOffset
-		// pp.Println("typeTreeCurrNameOffset", typeTreeCurrNameOffset)
+		typeTreeCurr.Name = typeTreeString(typeTreeData, typeTreeCurrNameOffset)
 
 		typeTreeCurrSize, err := typeTreeDataReader.ReadInt(isLittleEndian)
 		if err != nil {
 			return err
 		}
 		typeTreeCurr.Size = typeTreeCurrSize
-		// pp.Println("typeTreeCurrSize", typeTreeCurrSize)
 
 		typeTreeCurrIndex, err := typeTreeDataReader.ReadUint(isLittleEndian)
 		if err != nil {
 			return err
 		}
 		typeTreeCurr.Index = int64(typeTreeCurrIndex)
-		// pp.Println("typeTreeCurrIndex", typeTreeCurrIndex)
 
 		typeTreeCurrFlags, err := typeTreeDataReader.ReadInt(isLittleEndian)
 		if err != nil {
 			return err
 		}
 		typeTreeCurr.Flags = typeTreeCurrFlags
-		// pp.Println("typeTreeCurrFlags", typeTreeCurrFlags)
 
-		typeTree.Children = append(typeTree.Children, typeTreeCurr)
+		if format >= 19 {
+			refTypeHash, err := typeTreeDataReader.ReadUlong(isLittleEndian)
+			if err != nil {
+				return err
+			}
+			typeTreeCurr.RefTypeHash = refTypeHash
+		}
+
+		nodes = append(nodes, typeTreeNode{
+			depth: int(typeTreeDepth),
+			tree:  typeTreeCurr,
+		})
 	}
 
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	// 先頭ノード (depth 0) がルート
+	root, _ := nestTypeTreeNodes(nodes, 0)
+	root.ClassID = typeTree.ClassID
+	root.BufferBytes = typeTree.BufferBytes
+	root.Data = typeTree.Data
+	*typeTree = root
+
 	return nil
 }
 
-func parseTypeTreeOld(dataReader *DataReader, typeTree *TypeTree, isLittleEndian bool) error {
+func parseTypeTreeOld(dataReader *DataReader, typeTree *TypeTree, format uint32, isLittleEndian bool) error {
 	typeTreeType, err := dataReader.ReadStringNull(256)
 	if err != nil {
 		return err
@@ -252,46 +433,59 @@ func parseTypeTreeOld(dataReader *DataReader, typeTree *TypeTree, isLittleEndian
 	}
 	typeTree.Name = typeTreeName
 
-	typeTreeSize, err := dataReader.ReadInt(false)
+	typeTreeSize, err := dataReader.ReadInt(isLittleEndian)
 	if err != nil {
 		return err
 	}
 	typeTree.Size = typeTreeSize
 
-	typeTreeIndex, err := dataReader.ReadInt(false)
-	if err != nil {
-		return err
+	// format 2のみ variableCount を持つ
+	if format == 2 {
+		_, err = dataReader.ReadInt(isLittleEndian)
+		if err != nil {
+			return err
+		}
+	}
+
+	if format != 3 {
+		typeTreeIndex, err := dataReader.ReadInt(isLittleEndian)
+		if err != nil {
+			return err
+		}
+		typeTree.Index = int64(typeTreeIndex)
 	}
-	typeTree.Index = int64(typeTreeIndex)
 
-	typeTreeIsArray, err := dataReader.ReadInt(false)
+	typeTreeIsArray, err := dataReader.ReadInt(isLittleEndian)
 	if err != nil {
 		return err
 	}
 	if typeTreeIsArray > 0 {
 		typeTree.IsArray = true
+		typeTree.TypeFlags = 1
 	}
 
-	typeTreeVersion, err := dataReader.ReadInt(false)
+	typeTreeVersion, err := dataReader.ReadInt(isLittleEndian)
 	if err != nil {
 		return err
 	}
 	typeTree.Version = typeTreeVersion
 
-	typeTreeFlags, err := dataReader.ReadInt(false)
-	if err != nil {
-		return err
+	if format != 3 {
+		typeTreeFlags, err := dataReader.ReadInt(isLittleEndian)
+		if err != nil {
+			return err
+		}
+		typeTree.Flags = typeTreeFlags
 	}
-	typeTree.Flags = typeTreeFlags
 
-	numFiels, err := dataReader.ReadInt(false)
+	numFields, err := dataReader.ReadInt(isLittleEndian)
 	if err != nil {
 		return err
 	}
 
-	for i := int32(0); i < numFiels; i++ {
+	for i := int32(0); i < numFields; i++ {
 		typeTreeCurr := &TypeTree{}
-		err = parseTypeTreeOld(dataReader, typeTreeCurr, isLittleEndian)
+		err = parseTypeTreeOld(dataReader, typeTreeCurr, format, isLittleEndian)
 		if err != nil {
 			return err
 		}
@@ -302,9 +496,9 @@ func parseTypeTreeOld(dataReader *DataReader, typeTree *TypeTree, isLittleEndian
 
 func parseTypeTree(dataReader *DataReader, typeTree *TypeTree, format uint32, isLittleEndian bool) error {
 	if format == 10 || format >= 12 {
-		return parseTypeTree1012(dataReader, typeTree, isLittleEndian)
+		return parseTypeTree1012(dataReader, typeTree, format, isLittleEndian)
 	}
-	return parseTypeTreeOld(dataReader, typeTree, isLittleEndian)
+	return parseTypeTreeOld(dataReader, typeTree, format, isLittleEndian)
 }
 
 func ParseTypeTree(dataReader *DataReader, format uint32, isLittleEndian bool, classID ClassID) (*TypeTree, error) {
@@ -0,0 +1,350 @@
+package unity
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// fixtureBuffer Unityのレイアウトどおりにバイト列を組み立てる
+type fixtureBuffer struct {
+	bytes.Buffer
+	order binary.ByteOrder
+}
+
+func (b *fixtureBuffer) put(values ...interface{}) {
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			b.WriteString(v)
+			b.WriteByte(0)
+		case []byte:
+			b.Write(v)
+		default:
+			binary.Write(b, b.order, v)
+		}
+	}
+}
+
+// alignFrom baseからの位置を4バイト境界に揃える
+func (b *fixtureBuffer) alignFrom(base int) {
+	for (base+b.Len())%4 != 0 {
+		b.WriteByte(0)
+	}
+}
+
+// node32 format 19以降の32バイトのtype treeノード
+func (b *fixtureBuffer) node32(version int16, depth uint8, isArray uint8, typeOffset, nameOffset uint32, size, index, flags int32, refTypeHash uint64) {
+	b.put(version, depth, isArray, typeOffset, nameOffset, size, index, flags, refTypeHash)
+}
+
+// oldNode format 10 / 12未満の再帰的なtype treeノード (子の数まで)
+func (b *fixtureBuffer) oldNode(typeName, name string, size, index, isArray, version, flags, numChildren int32) {
+	b.put(typeName, name, size, index, isArray, version, flags, numChildren)
+}
+
+// common 共通文字列テーブルのオフセット
+func common(offset uint32) uint32 {
+	return 0x80000000 | offset
+}
+
+// testSerializedFile21 2019.4のMonoBehaviourを1つ持つformat 21のシリアライズファイル
+// スクリプトインデックス、スクリプトID、32バイトノード、型の依存関係、参照型を含む
+func testSerializedFile21() []byte {
+	scriptID := bytes.Repeat([]byte{0x5c}, 16)
+	hash := bytes.Repeat([]byte{0x4a}, 16)
+	refScriptID := bytes.Repeat([]byte{0x6d}, 16)
+	refHash := bytes.Repeat([]byte{0x7e}, 16)
+	guid := bytes.Repeat([]byte{0x01}, 16)
+
+	meta := &fixtureBuffer{order: binary.LittleEndian}
+	meta.put("2019.4.40f1", uint32(19), uint8(1))
+
+	// 型: MonoBehaviour
+	meta.put(int32(1))
+	meta.put(int32(114), uint8(0), int16(0), scriptID, hash)
+	localStrings := "m_FileID\x00m_PathID\x00m_Score\x00"
+	meta.put(uint32(13), uint32(len(localStrings)))
+	meta.node32(1, 0, 0, common(263), common(55), -1, 0, 0x8000, 0)     // MonoBehaviour Base
+	meta.node32(1, 1, 0, common(564), common(374), 12, 1, 0, 0)         // PPtr<GameObject> m_GameObject
+	meta.node32(1, 2, 0, common(222), 0, 4, 2, 0, 0)                    // int m_FileID
+	meta.node32(1, 2, 0, common(814), 9, 8, 3, 0, 0)                    // SInt64 m_PathID
+	meta.node32(1, 1, 0, common(928), common(349), 1, 4, 0x4000, 0)     // UInt8 m_Enabled
+	meta.node32(1, 1, 0, common(616), common(490), 12, 5, 0, 0)         // PPtr<MonoScript> m_Script
+	meta.node32(1, 2, 0, common(222), 0, 4, 6, 0, 0)                    // int m_FileID
+	meta.node32(1, 2, 0, common(814), 9, 8, 7, 0, 0)                    // SInt64 m_PathID
+	meta.node32(1, 1, 0, common(840), common(427), -1, 8, 0x8000, 0)    // string m_Name
+	meta.node32(1, 2, 1, common(49), common(49), -1, 9, 0x4001, 0)      // Array Array
+	meta.node32(1, 3, 0, common(222), common(795), 4, 10, 0x1, 0)       // int size
+	meta.node32(1, 3, 0, common(81), common(106), 1, 11, 0x1, 0)        // char data
+	meta.node32(1, 1, 0, common(222), 18, 4, 12, 0, 0x0123456789abcdef) // int m_Score
+	meta.put([]byte(localStrings))
+	meta.put(int32(1), int32(0)) // 依存する参照型
+
+	// オブジェクト
+	meta.put(int32(1))
+	meta.alignFrom(20)
+	meta.put(int64(2), uint32(0), uint32(44), int32(0))
+
+	// スクリプト参照 (MonoScript)
+	meta.put(int32(1), int32(1))
+	meta.alignFrom(20)
+	meta.put(int64(11500000))
+
+	// 外部参照
+	meta.put(int32(1), "", guid, int32(3), "Library/ScriptAssemblies/Assembly-CSharp.dll")
+
+	// 参照型 (SerializeReference)
+	refStrings := "MyData\x00m_Value\x00"
+	meta.put(int32(1))
+	meta.put(int32(114), uint8(0), int16(0), refScriptID, refHash)
+	meta.put(uint32(2), uint32(len(refStrings)))
+	meta.node32(1, 0, 0, 0, common(55), -1, 0, 0, 0) // MyData Base
+	meta.node32(1, 1, 0, common(222), 7, 4, 1, 0, 0) // int m_Value
+	meta.put([]byte(refStrings))
+	meta.put("MyData", "Game", "Assembly-CSharp")
+
+	// ユーザー情報
+	meta.put("")
+
+	data := &fixtureBuffer{order: binary.LittleEndian}
+	data.put(int32(0), int64(1))         // m_GameObject
+	data.put(uint8(1), []byte{0, 0, 0})  // m_Enabled
+	data.put(int32(1), int64(11500000))  // m_Script
+	data.put(int32(6), []byte("Player")) // m_Name
+	data.put([]byte{0, 0})
+	data.put(int32(42)) // m_Score
+
+	dataOffset := int(alignOffset(int64(20+meta.Len()), 16))
+	header := &fixtureBuffer{order: binary.BigEndian}
+	header.put(uint32(meta.Len()), uint32(dataOffset+data.Len()), uint32(21), uint32(dataOffset))
+	header.put([]byte{0, 0, 0, 0})
+
+	b := make([]byte, dataOffset, dataOffset+data.Len())
+	copy(b, header.Bytes())
+	copy(b[20:], meta.Bytes())
+	return append(b, data.Bytes()...)
+}
+
+// testSerializedFile9 Unity 3.5のGameObjectを1つ持つビッグエンディアンのformat 9のシリアライズファイル
+// 再帰的なtype treeを含む
+func testSerializedFile9() []byte {
+	guid := bytes.Repeat([]byte{0x02}, 16)
+
+	meta := &fixtureBuffer{order: binary.BigEndian}
+	meta.put("3.5.7f6", int32(5))
+
+	// 型: GameObject
+	meta.put(int32(1))
+	meta.put(int32(1))
+	meta.oldNode("GameObject", "Base", -1, 0, 0, 2, 0x8000, 3)
+	meta.oldNode("unsigned int", "m_Layer", 4, 1, 0, 1, 0, 0)
+	meta.oldNode("string", "m_Name", -1, 2, 0, 1, 0x8000, 1)
+	meta.oldNode("Array", "Array", -1, 3, 1, 1, 0x4001, 2)
+	meta.oldNode("int", "size", 4, 4, 0, 1, 0x1, 0)
+	meta.oldNode("char", "data", 1, 5, 0, 1, 0x1, 0)
+	meta.oldNode("bool", "m_IsActive", 1, 6, 0, 1, 0, 0)
+
+	// 64bitのPathIDは使わない
+	meta.put(int32(0))
+
+	// オブジェクト
+	meta.put(int32(1))
+	meta.put(int32(1), uint32(0), uint32(13), int32(1), uint16(1), uint16(0))
+
+	// 外部参照
+	meta.put(int32(1), "", guid, int32(3), "library/unity default resources")
+
+	// ユーザー情報
+	meta.put("")
+
+	data := &fixtureBuffer{order: binary.BigEndian}
+	data.put(uint32(5), int32(4), []byte("Cube"), uint8(1))
+
+	dataOffset := int(alignOffset(int64(20+meta.Len()), 16))
+	header := &fixtureBuffer{order: binary.BigEndian}
+	header.put(uint32(meta.Len()), uint32(dataOffset+data.Len()), uint32(9), uint32(dataOffset))
+	header.put([]byte{1, 0, 0, 0})
+
+	b := make([]byte, dataOffset, dataOffset+data.Len())
+	copy(b, header.Bytes())
+	copy(b[20:], meta.Bytes())
+	return append(b, data.Bytes()...)
+}
+
+// typeTreeShape type treeの型と名前をインデントして並べる
+func typeTreeShape(typeTree *TypeTree, depth int) []string {
+	shape := []string{string(bytes.Repeat([]byte{' '}, depth)) + typeTree.Type + " " + typeTree.Name}
+	for i := range typeTree.Children {
+		shape = append(shape, typeTreeShape(&typeTree.Children[i], depth+1)...)
+	}
+	return shape
+}
+
+func TestParseSerializedFileFormat21(t *testing.T) {
+	b := testSerializedFile21()
+	f, err := ParseSerializedFile(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	typeMetadata := f.TypeMetadata
+	if f.Format != 21 || !f.IsLittleEndian || typeMetadata.PlayerVersion != "2019.4.40f1" || typeMetadata.TargetPlatform != 19 || !typeMetadata.HasTypeTrees {
+		t.Fatalf("unexpected header: %+v %+v", f, typeMetadata)
+	}
+
+	hash := typeMetadata.Hashes[0]
+	if hash.ClassID != 114 || hash.ScriptTypeIndex != 0 || hash.IsStrippedType ||
+		!bytes.Equal(hash.ScriptID, bytes.Repeat([]byte{0x5c}, 16)) ||
+		!bytes.Equal(hash.Hash, bytes.Repeat([]byte{0x4a}, 16)) ||
+		!reflect.DeepEqual(hash.TypeDependencies, []int32{0}) {
+		t.Fatalf("unexpected type: %+v", hash)
+	}
+
+	typeTree := &typeMetadata.TypeTrees[0]
+	shape := []string{
+		"MonoBehaviour Base",
+		" PPtr<GameObject> m_GameObject",
+		"  int m_FileID",
+		"  SInt64 m_PathID",
+		" UInt8 m_Enabled",
+		" PPtr<MonoScript> m_Script",
+		"  int m_FileID",
+		"  SInt64 m_PathID",
+		" string m_Name",
+		"  Array Array",
+		"   int size",
+		"   char data",
+		" int m_Score",
+	}
+	if got := typeTreeShape(typeTree, 0); !reflect.DeepEqual(got, shape) {
+		t.Fatalf("unexpected type tree:\n got %q\nwant %q", got, shape)
+	}
+	if !typeTree.Children[3].Children[0].IsArray || typeTree.Children[4].RefTypeHash != 0x0123456789abcdef {
+		t.Fatal("unexpected node flags")
+	}
+
+	if len(f.Objects) != 1 || f.Objects[0].PathID != 2 || f.Objects[0].ClassID != 114 || f.Objects[0].Size != 44 {
+		t.Fatalf("unexpected objects: %+v", f.Objects)
+	}
+	if !reflect.DeepEqual(f.Adds, []ObjectAdd{{FileIndex: 1, PathID: 11500000}}) {
+		t.Fatalf("unexpected script references: %+v", f.Adds)
+	}
+	if len(f.Externals) != 1 || f.Externals[0].FilePath != "Library/ScriptAssemblies/Assembly-CSharp.dll" || f.Externals[0].Type != 3 {
+		t.Fatalf("unexpected externals: %+v", f.Externals)
+	}
+
+	refType := typeMetadata.RefTypes[0]
+	if refType.ClassName != "MyData" || refType.Namespace != "Game" || refType.AssemblyName != "Assembly-CSharp" ||
+		!bytes.Equal(refType.ScriptID, bytes.Repeat([]byte{0x6d}, 16)) {
+		t.Fatalf("unexpected ref type: %+v", refType)
+	}
+	if got := typeTreeShape(&typeMetadata.RefTypeTrees[0], 0); !reflect.DeepEqual(got, []string{"MyData Base", " int m_Value"}) {
+		t.Fatalf("unexpected ref type tree: %q", got)
+	}
+
+	value, err := f.ReadObjectValue(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]interface{}{"m_Enabled": uint8(1), "m_Name": "Player", "m_Score": int32(42)} {
+		field, ok := value.Field(name)
+		if !ok || field.Value != want {
+			t.Fatalf("unexpected %s: %+v", name, field)
+		}
+	}
+	script, _ := value.Field("m_Script")
+	pathID, _ := script.Field("m_PathID")
+	if pathID.Value != int64(11500000) {
+		t.Fatalf("unexpected m_Script: %+v", pathID)
+	}
+
+	var buf bytes.Buffer
+	_, err = f.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Fatal("rewritten file differs from the fixture")
+	}
+}
+
+func TestParseSerializedFileFormat9(t *testing.T) {
+	b := testSerializedFile9()
+	f, err := ParseSerializedFile(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	typeMetadata := f.TypeMetadata
+	if f.Format != 9 || f.IsLittleEndian || typeMetadata.PlayerVersion != "3.5.7f6" || typeMetadata.TargetPlatform != 5 {
+		t.Fatalf("unexpected header: %+v %+v", f, typeMetadata)
+	}
+	if len(typeMetadata.Hashes) != 1 || typeMetadata.Hashes[0].ClassID != 1 || typeMetadata.Hashes[0].Hash != nil {
+		t.Fatalf("unexpected types: %+v", typeMetadata.Hashes)
+	}
+
+	shape := []string{
+		"GameObject Base",
+		" unsigned int m_Layer",
+		" string m_Name",
+		"  Array Array",
+		"   int size",
+		"   char data",
+		" bool m_IsActive",
+	}
+	if got := typeTreeShape(&typeMetadata.TypeTrees[0], 0); !reflect.DeepEqual(got, shape) {
+		t.Fatalf("unexpected type tree:\n got %q\nwant %q", got, shape)
+	}
+
+	if len(f.Objects) != 1 || f.Objects[0].PathID != 1 || f.Objects[0].ClassID != 1 || f.Objects[0].Size != 13 {
+		t.Fatalf("unexpected objects: %+v", f.Objects)
+	}
+	if len(f.Externals) != 1 || f.Externals[0].FilePath != "library/unity default resources" {
+		t.Fatalf("unexpected externals: %+v", f.Externals)
+	}
+
+	value, err := f.ReadObjectValue(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]interface{}{"m_Layer": uint32(5), "m_Name": "Cube", "m_IsActive": true} {
+		field, ok := value.Field(name)
+		if !ok || field.Value != want {
+			t.Fatalf("unexpected %s: %+v", name, field)
+		}
+	}
+
+	var buf bytes.Buffer
+	_, err = f.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Fatal("rewritten file differs from the fixture")
+	}
+}
+
+func TestParseTypeTreeCorruptCounts(t *testing.T) {
+	for _, counts := range [][2]uint32{
+		{0xffffffff, 0}, // ノード数の掛け算が溢れる
+		{0x0aaaaaab, 0}, // 32ビットのintでは溢れる
+		{1, 0xfffffff0}, // バッファがデータを超える
+		{2, 0},          // ノードがデータを超える
+		{1, 2},          // ノードとバッファの合計がデータを超える
+	} {
+		buf := &fixtureBuffer{order: binary.LittleEndian}
+		buf.put(counts[0], counts[1])
+		buf.Write(make([]byte, 25))
+
+		dataReader, err := NewDataReader(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = parseTypeTree1012(dataReader, &TypeTree{}, 17, true)
+		if err != ErrInvalidTypeTree {
+			t.Fatalf("%v: expected ErrInvalidTypeTree, got %v", counts, err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package unity
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/md4"
+)
+
+// ComputeTypeHash type treeから型ハッシュ (TypeMetadataHash.Hash) を計算する
+// 各ノードの型名、名前、サイズ、typeFlags、バージョン、アラインのフラグを先行順にMD4へ入力する
+func ComputeTypeHash(typeTree *TypeTree) []byte {
+	h := md4.New()
+	writeTypeHash(h, typeTree)
+	return h.Sum(nil)
+}
+
+func writeTypeHash(h hash.Hash, typeTree *TypeTree) {
+	h.Write([]byte(typeTree.Type))
+	h.Write([]byte(typeTree.Name))
+	binary.Write(h, binary.LittleEndian, typeTree.Size)
+	binary.Write(h, binary.LittleEndian, int32(typeTree.typeFlags()))
+	binary.Write(h, binary.LittleEndian, typeTree.Version)
+	binary.Write(h, binary.LittleEndian, typeTree.Flags&typeTreeFlagAlignBytes)
+
+	for i := range typeTree.Children {
+		writeTypeHash(h, &typeTree.Children[i])
+	}
+}
+
+// TypeHashMismatch 保存されている型ハッシュとtype treeから計算した型ハッシュの不一致 (type treeのずれ)
+type TypeHashMismatch struct {
+	Index     int
+	IsRefType bool
+	ClassID   ClassID
+	Type      string
+	Stored    []byte
+	Computed  []byte
+}
+
+func (m TypeHashMismatch) String() string {
+	kind := "type"
+	if m.IsRefType {
+		kind = "ref type"
+	}
+	return fmt.Sprintf("type tree drift: %s %d (ClassID: %d) %s: stored %s, computed %s",
+		kind, m.Index, m.ClassID, m.Type, hex.EncodeToString(m.Stored), hex.EncodeToString(m.Computed))
+}
+
+// VerifyTypeHashes 型ハッシュをtype treeから計算して保存されている値と比べ、一致しないものを返す
+// type treeが除かれている場合やハッシュを持たないformat 12以前では何も返さない
+func (f *SerializedFile) VerifyTypeHashes() []TypeHashMismatch {
+	mismatches := []TypeHashMismatch{}
+	typeMetadata := f.TypeMetadata
+	if typeMetadata == nil || !typeMetadata.HasTypeTrees {
+		return mismatches
+	}
+
+	mismatches = verifyTypeHashes(mismatches, typeMetadata.Hashes, typeMetadata.TypeTrees, false)
+	mismatches = verifyTypeHashes(mismatches, typeMetadata.RefTypes, typeMetadata.RefTypeTrees, true)
+	return mismatches
+}
+
+func verifyTypeHashes(mismatches []TypeHashMismatch, hashes []TypeMetadataHash, typeTrees []TypeTree, isRefType bool) []TypeHashMismatch {
+	for i, typeMetadataHash := range hashes {
+		if i >= len(typeTrees) || len(typeMetadataHash.Hash) == 0 {
+			continue
+		}
+
+		typeTree := &typeTrees[i]
+		computed := ComputeTypeHash(typeTree)
+		if !bytes.Equal(computed, typeMetadataHash.Hash) {
+			mismatches = append(mismatches, TypeHashMismatch{
+				Index:     i,
+				IsRefType: isRefType,
+				ClassID:   typeMetadataHash.ClassID,
+				Type:      typeTree.Type,
+				Stored:    typeMetadataHash.Hash,
+				Computed:  computed,
+			})
+		}
+	}
+	return mismatches
+}
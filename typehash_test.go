@@ -0,0 +1,66 @@
+package unity
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVerifyTypeHashes(t *testing.T) {
+	f := testSerializedFile(22, true)
+
+	computed := ComputeTypeHash(&f.TypeMetadata.TypeTrees[0])
+	if len(computed) != 16 {
+		t.Fatalf("unexpected hash size: %d", len(computed))
+	}
+
+	mismatches := f.VerifyTypeHashes()
+	if len(mismatches) != 1 || !bytes.Equal(mismatches[0].Computed, computed) || mismatches[0].ClassID != 1001 {
+		t.Fatalf("unexpected mismatches: %v", mismatches)
+	}
+
+	f.TypeMetadata.Hashes[0].Hash = computed
+	mismatches = f.VerifyTypeHashes()
+	if len(mismatches) != 0 {
+		t.Fatalf("unexpected mismatches: %v", mismatches)
+	}
+
+	// アラインのフラグ以外のフラグはハッシュに影響しない
+	f.TypeMetadata.TypeTrees[0].Children[0].Flags |= 0x1
+	if !bytes.Equal(ComputeTypeHash(&f.TypeMetadata.TypeTrees[0]), computed) {
+		t.Fatal("hash should ignore flags other than align")
+	}
+
+	f.TypeMetadata.TypeTrees[0].Children[1].Flags = 0
+	mismatches = f.VerifyTypeHashes()
+	if len(mismatches) != 1 {
+		t.Fatalf("alignment change should be reported: %v", mismatches)
+	}
+}
+
+func TestTypeFlags(t *testing.T) {
+	// SerializeReferenceのノードはtypeFlagsのbit 1が立つが配列ではない
+	f := testSerializedFile(21, true)
+	before := ComputeTypeHash(&f.TypeMetadata.TypeTrees[0])
+	f.TypeMetadata.TypeTrees[0].Children[0].TypeFlags = 2
+	if bytes.Equal(ComputeTypeHash(&f.TypeMetadata.TypeTrees[0]), before) {
+		t.Fatal("hash should include the raw typeFlags")
+	}
+
+	var buf bytes.Buffer
+	_, err := f.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseSerializedFile(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := parsed.TypeMetadata.TypeTrees[0].Children[0]
+	if node.TypeFlags != 2 || node.IsArray {
+		t.Fatalf("unexpected typeFlags: %d, IsArray: %v", node.TypeFlags, node.IsArray)
+	}
+	array := parsed.TypeMetadata.TypeTrees[0].Children[0].Children[0]
+	if array.TypeFlags != 1 || !array.IsArray {
+		t.Fatalf("unexpected typeFlags: %d, IsArray: %v", array.TypeFlags, array.IsArray)
+	}
+}
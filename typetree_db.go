@@ -0,0 +1,344 @@
+package unity
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// SignatureTypeTreeDatabase type treeデータベースファイルのシグネチャ
+const SignatureTypeTreeDatabase = "UnityTypeTreeDB"
+
+// typeTreeDatabaseVersion type treeデータベースファイルの形式のバージョン
+const typeTreeDatabaseVersion = 1
+
+// typeTreeDatabaseTreeFormat データベース内のtype treeを書き出す際のシリアライズファイルのformat (32バイトノード)
+const typeTreeDatabaseTreeFormat = 19
+
+// DefaultTypeTreeDatabase SerializedFile.TypeTreeDatabaseが未設定の場合に使うデータベース
+var DefaultTypeTreeDatabase *TypeTreeDatabase
+
+// TypeTreeDatabase Unityバージョン、ClassID、型ハッシュをキーにしたtype tree
+// DisableWriteTypeTreeでtype treeが除かれたファイルのデコードに使う
+type TypeTreeDatabase struct {
+	entries map[typeTreeDatabaseKey]*TypeTree
+
+	// byHash ClassIDと型ハッシュから、登録されている中で最も古いUnityバージョンを引く索引
+	byHash map[typeTreeDatabaseHashKey]string
+}
+
+type typeTreeDatabaseKey struct {
+	unityVersion string
+	classID      ClassID
+	hash         [16]byte
+}
+
+type typeTreeDatabaseHashKey struct {
+	classID ClassID
+	hash    [16]byte
+}
+
+// NewTypeTreeDatabase new TypeTreeDatabase instance
+func NewTypeTreeDatabase() *TypeTreeDatabase {
+	return &TypeTreeDatabase{
+		entries: map[typeTreeDatabaseKey]*TypeTree{},
+		byHash:  map[typeTreeDatabaseHashKey]string{},
+	}
+}
+
+func newTypeTreeDatabaseKey(unityVersion string, classID ClassID, hash []byte) (typeTreeDatabaseKey, error) {
+	key := typeTreeDatabaseKey{
+		unityVersion: unityVersion,
+		classID:      classID,
+	}
+	if len(hash) != len(key.hash) {
+		return key, ErrInvalidHashSize
+	}
+	copy(key.hash[:], hash)
+	return key, nil
+}
+
+// Len 登録されているtype treeの数
+func (db *TypeTreeDatabase) Len() int {
+	return len(db.entries)
+}
+
+// Add type treeの複製を登録する。同じキーが既にある場合は置き換える
+func (db *TypeTreeDatabase) Add(unityVersion string, classID ClassID, hash []byte, typeTree *TypeTree) error {
+	key, err := newTypeTreeDatabaseKey(unityVersion, classID, hash)
+	if err != nil {
+		return err
+	}
+	db.entries[key] = copyTypeTree(typeTree)
+
+	hashKey := typeTreeDatabaseHashKey{classID: key.classID, hash: key.hash}
+	if v, ok := db.byHash[hashKey]; !ok || compareUnityVersions(unityVersion, v) < 0 {
+		db.byHash[hashKey] = unityVersion
+	}
+	return nil
+}
+
+// compareUnityVersions Unityバージョンの文字列を数値として比べる
+// パースできないものや同じ値になるものは文字列として比べ、常に順序が決まるようにする
+func compareUnityVersions(a, b string) int {
+	va := NewVersionInfo(a)
+	vb := NewVersionInfo(b)
+	if va != nil && vb != nil {
+		if c := va.Compare(vb); c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// copyTypeTree 子と文字列バッファを含めてtype treeを複製する
+func copyTypeTree(typeTree *TypeTree) *TypeTree {
+	c := *typeTree
+	if typeTree.Data != nil {
+		c.Data = append([]byte{}, typeTree.Data...)
+	}
+	if typeTree.Children != nil {
+		c.Children = make([]TypeTree, len(typeTree.Children))
+		for i := range typeTree.Children {
+			c.Children[i] = *copyTypeTree(&typeTree.Children[i])
+		}
+	}
+	return &c
+}
+
+// AddSerializedFile type treeを持つシリアライズファイルの全ての型を登録し、登録した数を返す
+func (db *TypeTreeDatabase) AddSerializedFile(f *SerializedFile) (int, error) {
+	typeMetadata := f.TypeMetadata
+	if typeMetadata == nil || !typeMetadata.HasTypeTrees || f.Format < 13 {
+		return 0, nil
+	}
+
+	n := 0
+	for i, hash := range typeMetadata.Hashes {
+		if i >= len(typeMetadata.TypeTrees) {
+			break
+		}
+		err := db.Add(typeMetadata.PlayerVersion, hash.ClassID, hash.Hash, &typeMetadata.TypeTrees[i])
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Lookup type treeを探す
+// 同じUnityバージョンのものが無い場合は、ClassIDと型ハッシュが一致する他のバージョンのうち最も古いものを返す
+func (db *TypeTreeDatabase) Lookup(unityVersion string, classID ClassID, hash []byte) (*TypeTree, bool) {
+	key, err := newTypeTreeDatabaseKey(unityVersion, classID, hash)
+	if err != nil {
+		return nil, false
+	}
+
+	if typeTree, ok := db.entries[key]; ok {
+		return typeTree, true
+	}
+
+	unityVersion, ok := db.byHash[typeTreeDatabaseHashKey{classID: key.classID, hash: key.hash}]
+	if !ok {
+		return nil, false
+	}
+	key.unityVersion = unityVersion
+	return db.entries[key], true
+}
+
+// TypeTrees 指定したUnityバージョンのtype treeをClassID順に返す。空文字列の場合は全バージョン
+func (db *TypeTreeDatabase) TypeTrees(unityVersion string) []*TypeTree {
+	typeTrees := []*TypeTree{}
+	for _, key := range db.sortedKeys() {
+		if unityVersion == "" || key.unityVersion == unityVersion {
+			typeTrees = append(typeTrees, db.entries[key])
+		}
+	}
+	return typeTrees
+}
+
+// sortedKeys キーをUnityバージョン、ClassID、型ハッシュの順に並べる
+func (db *TypeTreeDatabase) sortedKeys() []typeTreeDatabaseKey {
+	keys := make([]typeTreeDatabaseKey, 0, len(db.entries))
+	for k := range db.entries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].unityVersion != keys[j].unityVersion {
+			return compareUnityVersions(keys[i].unityVersion, keys[j].unityVersion) < 0
+		}
+		if keys[i].classID != keys[j].classID {
+			return keys[i].classID < keys[j].classID
+		}
+		return bytes.Compare(keys[i].hash[:], keys[j].hash[:]) < 0
+	})
+	return keys
+}
+
+// WriteTo implements the io.WriterTo interface.
+// 形式 (全てリトルエンディアン):
+//
+//	signature\0, uint32 version, uint32 numEntries,
+//	entry: unityVersion\0, int32 classID, hash[16], type tree (format 19のノード形式)
+func (db *TypeTreeDatabase) WriteTo(w io.Writer) (int64, error) {
+	dataWriter := NewDataWriter()
+
+	err := dataWriter.WriteStringNull(SignatureTypeTreeDatabase)
+	if err != nil {
+		return 0, err
+	}
+
+	err = dataWriter.WriteUint(typeTreeDatabaseVersion, true)
+	if err != nil {
+		return 0, err
+	}
+
+	err = dataWriter.WriteUint(uint32(len(db.entries)), true)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, key := range db.sortedKeys() {
+		err = dataWriter.WriteStringNull(key.unityVersion)
+		if err != nil {
+			return 0, err
+		}
+
+		err = dataWriter.WriteInt(int32(key.classID), true)
+		if err != nil {
+			return 0, err
+		}
+
+		err = dataWriter.WriteBytes(key.hash[:])
+		if err != nil {
+			return 0, err
+		}
+
+		err = writeTypeTree1012(dataWriter, db.entries[key], typeTreeDatabaseTreeFormat, true)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.Write(dataWriter.Bytes())
+	return int64(n), err
+}
+
+// ReadTypeTreeDatabase type treeデータベースを読み込む
+func ReadTypeTreeDatabase(r io.Reader) (*TypeTreeDatabase, error) {
+	// Bundle等の大きなファイルを渡された場合に全体を読み込まないよう、先にシグネチャを確認する
+	signature := make([]byte, len(SignatureTypeTreeDatabase)+1)
+	_, err := io.ReadFull(r, signature)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, ErrInvalidTypeTreeDatabase
+	}
+	if err != nil {
+		return nil, err
+	}
+	if string(signature) != SignatureTypeTreeDatabase+"\x00" {
+		return nil, ErrInvalidTypeTreeDatabase
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dataReader, err := NewDataReader(b)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := dataReader.ReadUint(true)
+	if err != nil {
+		return nil, err
+	}
+	if version != typeTreeDatabaseVersion {
+		return nil, ErrInvalidTypeTreeDatabase
+	}
+
+	numEntries, err := dataReader.ReadUint(true)
+	if err != nil {
+		return nil, err
+	}
+
+	db := NewTypeTreeDatabase()
+	for i := 0; i < int(numEntries); i++ {
+		unityVersion, err := dataReader.ReadStringNull(256)
+		if err != nil {
+			return nil, err
+		}
+
+		classID, err := dataReader.ReadInt(true)
+		if err != nil {
+			return nil, err
+		}
+
+		hash, err := dataReader.ReadBytes(16, true)
+		if err != nil {
+			return nil, err
+		}
+
+		typeTree, err := ParseTypeTree(dataReader, typeTreeDatabaseTreeFormat, true, ClassID(classID))
+		if err != nil {
+			return nil, err
+		}
+
+		err = db.Add(unityVersion, ClassID(classID), hash, typeTree)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// LoadTypeTreeDatabase ファイルパスからtype treeデータベースを読み込む
+func LoadTypeTreeDatabase(path string) (*TypeTreeDatabase, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	return ReadTypeTreeDatabase(bufio.NewReader(fp))
+}
+
+// typeTreeFromDatabase type treeが除かれたファイルのオブジェクトのtype treeをデータベースから探す
+func (f *SerializedFile) typeTreeFromDatabase(obj *ObjectInfo) (*TypeTree, error) {
+	db := f.TypeTreeDatabase
+	if db == nil {
+		db = DefaultTypeTreeDatabase
+	}
+	typeMetadata := f.TypeMetadata
+	if db == nil || typeMetadata == nil {
+		return nil, ErrTypeTreeNotFound
+	}
+
+	var hash *TypeMetadataHash
+	if f.Format >= 16 {
+		if obj.TypeID < 0 || int(obj.TypeID) >= len(typeMetadata.Hashes) {
+			return nil, ErrInvalidTypeID
+		}
+		hash = &typeMetadata.Hashes[obj.TypeID]
+	} else {
+		for i := range typeMetadata.Hashes {
+			if typeMetadata.Hashes[i].ClassID == ClassID(obj.TypeID) {
+				hash = &typeMetadata.Hashes[i]
+				break
+			}
+		}
+	}
+	if hash == nil {
+		return nil, ErrTypeTreeNotFound
+	}
+
+	typeTree, ok := db.Lookup(typeMetadata.PlayerVersion, hash.ClassID, hash.Hash)
+	if !ok {
+		return nil, ErrTypeTreeNotFound
+	}
+	return typeTree, nil
+}
@@ -0,0 +1,125 @@
+package unity
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestTypeTreeDatabase(t *testing.T) {
+	source := testSerializedFile(22, true)
+	_, err := source.WriteTo(&bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := NewTypeTreeDatabase()
+	n, err := db.AddSerializedFile(source)
+	if err != nil || n != 1 {
+		t.Fatalf("unexpected result: %d, %v", n, err)
+	}
+
+	// 登録したtype treeは元のファイルと共有しない
+	hash := source.TypeMetadata.Hashes[0]
+	source.TypeMetadata.TypeTrees[0].Children[0].Name = "m_Changed"
+	typeTree, ok := db.Lookup(source.TypeMetadata.PlayerVersion, hash.ClassID, hash.Hash)
+	if !ok || typeTree.Children[0].Name != "m_Name" {
+		t.Fatal("registered type tree shares nodes with the source file")
+	}
+	source.TypeMetadata.TypeTrees[0].Children[0].Name = "m_Name"
+	before := copyTypeTree(&source.TypeMetadata.TypeTrees[0])
+
+	var buf bytes.Buffer
+	_, err = db.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&source.TypeMetadata.TypeTrees[0], before) {
+		t.Fatal("WriteTo modified the source type tree")
+	}
+	loaded, err := ReadTypeTreeDatabase(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != 1 {
+		t.Fatalf("unexpected number of entries: %d", loaded.Len())
+	}
+
+	// シグネチャが違う場合は続きを読まない
+	_, err = ReadTypeTreeDatabase(io.MultiReader(strings.NewReader("UnityFS\x00\x00\x00\x00\x085.x.x\x002018.4.0f1\x00"), iotest.ErrReader(io.ErrClosedPipe)))
+	if err != ErrInvalidTypeTreeDatabase {
+		t.Fatalf("expected ErrInvalidTypeTreeDatabase, got %v", err)
+	}
+	_, err = ReadTypeTreeDatabase(strings.NewReader("Unity"))
+	if err != ErrInvalidTypeTreeDatabase {
+		t.Fatalf("expected ErrInvalidTypeTreeDatabase, got %v", err)
+	}
+
+	// DisableWriteTypeTree相当のファイル
+	stripped := testSerializedFile(22, true)
+	stripped.TypeMetadata.HasTypeTrees = false
+	stripped.TypeMetadata.TypeTrees = nil
+	buf.Reset()
+	_, err = stripped.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseSerializedFile(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = parsed.ReadObjectValue(1)
+	if err != ErrTypeTreeNotFound {
+		t.Fatalf("expected ErrTypeTreeNotFound, got %v", err)
+	}
+
+	parsed.TypeTreeDatabase = loaded
+	value, err := parsed.ReadObjectValue(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, _ := value.Field("m_Name")
+	if name.Value != "hello" {
+		t.Fatalf("unexpected m_Name %v", name.Value)
+	}
+
+	// 別バージョンでも型ハッシュが一致すれば使う
+	parsed.TypeMetadata.PlayerVersion = "2021.3.0f1"
+	_, err = parsed.ReadObjectValue(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTypeTreeDatabaseOldestVersion(t *testing.T) {
+	hash := bytes.Repeat([]byte{0xcd}, 16)
+	add := func(db *TypeTreeDatabase, unityVersion string) {
+		err := db.Add(unityVersion, 1001, hash, &TypeTree{ClassID: 1001, Type: unityVersion})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 文字列としての順序と数値としての順序が異なるバージョン
+	for _, c := range []struct {
+		versions []string
+		oldest   string
+	}{
+		{[]string{"2019.4.0f1", "5.6.0f1"}, "5.6.0f1"},
+		{[]string{"2020.3.10f1", "2020.3.9f1"}, "2020.3.9f1"},
+		{[]string{"2020.3.9f1", "2020.3.10f1", "2020.3.9b2"}, "2020.3.9b2"},
+	} {
+		db := NewTypeTreeDatabase()
+		for _, v := range c.versions {
+			add(db, v)
+		}
+		typeTree, ok := db.Lookup("2022.3.0f1", 1001, hash)
+		if !ok || typeTree.Type != c.oldest {
+			t.Fatalf("%v: expected %s, got %+v", c.versions, c.oldest, typeTree)
+		}
+	}
+}
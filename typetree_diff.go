@@ -0,0 +1,397 @@
+package unity
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	// FieldAdded フィールドが追加された
+	FieldAdded = "added"
+
+	// FieldRemoved フィールドが削除された
+	FieldRemoved = "removed"
+
+	// FieldRetyped フィールドの型が変わった
+	FieldRetyped = "retyped"
+
+	// FieldReordered フィールドの順序が変わった
+	FieldReordered = "reordered"
+
+	// FieldAlignmentChanged フィールドのアライン (kAlignBytesFlag) が変わった
+	FieldAlignmentChanged = "alignment"
+)
+
+const (
+	// ClassAdded 新しいtype treeにのみあるクラス
+	ClassAdded = "added"
+
+	// ClassRemoved 古いtype treeにのみあるクラス
+	ClassRemoved = "removed"
+
+	// ClassChanged レイアウトが変わったクラス
+	ClassChanged = "changed"
+
+	// ClassAmbiguous 同じClassIDとScriptIDで異なるレイアウトが複数あり、対応付けられないクラス
+	ClassAmbiguous = "ambiguous"
+)
+
+// FieldChange フィールドの変更
+// Pathはルートからのフィールド名を"."で繋いだもの (m_Component.Array.data)
+type FieldChange struct {
+	Kind       string `json:"kind"`
+	Path       string `json:"path"`
+	OldType    string `json:"oldType,omitempty"`
+	NewType    string `json:"newType,omitempty"`
+	OldIndex   int    `json:"oldIndex"`
+	NewIndex   int    `json:"newIndex"`
+	OldAligned bool   `json:"oldAligned"`
+	NewAligned bool   `json:"newAligned"`
+}
+
+// ClassDiff ClassIDとScriptIDごとの変更
+// ScriptIDは16進数の文字列で、スクリプトの型以外では空になる
+// OldLayouts / NewLayouts はClassAmbiguousの場合のみ、それぞれのレイアウトの数を示す
+type ClassDiff struct {
+	ClassID    ClassID       `json:"classId"`
+	ScriptID   string        `json:"scriptId,omitempty"`
+	Type       string        `json:"type"`
+	Status     string        `json:"status"`
+	OldLayouts int           `json:"oldLayouts,omitempty"`
+	NewLayouts int           `json:"newLayouts,omitempty"`
+	Changes    []FieldChange `json:"changes,omitempty"`
+}
+
+// DiffTypeTree 2つのtype treeのフィールドを名前で対応付け、追加、削除、型の変更、順序の変更、アラインの変更を返す
+// 型が変わったフィールドの子は比較しない
+func DiffTypeTree(oldTree, newTree *TypeTree) []FieldChange {
+	return diffTypeTreeChildren([]FieldChange{}, "", oldTree, newTree)
+}
+
+func diffTypeTreeChildren(changes []FieldChange, parent string, oldTree, newTree *TypeTree) []FieldChange {
+	fieldPath := func(name string) string {
+		if parent == "" {
+			return name
+		}
+		return parent + "." + name
+	}
+
+	oldIndex := map[string]int{}
+	for i := range oldTree.Children {
+		if _, ok := oldIndex[oldTree.Children[i].Name]; !ok {
+			oldIndex[oldTree.Children[i].Name] = i
+		}
+	}
+	newIndex := map[string]int{}
+	for i := range newTree.Children {
+		if _, ok := newIndex[newTree.Children[i].Name]; !ok {
+			newIndex[newTree.Children[i].Name] = i
+		}
+	}
+
+	// 両方にあるフィールドのうち、最長共通部分列に含まれないものを順序が変わったとみなす
+	oldCommon := []string{}
+	for i := range oldTree.Children {
+		name := oldTree.Children[i].Name
+		if _, ok := newIndex[name]; ok && oldIndex[name] == i {
+			oldCommon = append(oldCommon, name)
+		}
+	}
+	newCommon := []string{}
+	for i := range newTree.Children {
+		name := newTree.Children[i].Name
+		if _, ok := oldIndex[name]; ok && newIndex[name] == i {
+			newCommon = append(newCommon, name)
+		}
+	}
+	inOrder := longestCommonSubsequence(oldCommon, newCommon)
+
+	for i := range oldTree.Children {
+		oldChild := &oldTree.Children[i]
+		if oldIndex[oldChild.Name] != i {
+			continue
+		}
+		if _, ok := newIndex[oldChild.Name]; !ok {
+			changes = append(changes, FieldChange{
+				Kind:       FieldRemoved,
+				Path:       fieldPath(oldChild.Name),
+				OldType:    oldChild.Type,
+				OldIndex:   i,
+				NewIndex:   -1,
+				OldAligned: oldChild.isAligned(),
+			})
+		}
+	}
+
+	for j := range newTree.Children {
+		newChild := &newTree.Children[j]
+		if newIndex[newChild.Name] != j {
+			continue
+		}
+
+		i, ok := oldIndex[newChild.Name]
+		if !ok {
+			changes = append(changes, FieldChange{
+				Kind:       FieldAdded,
+				Path:       fieldPath(newChild.Name),
+				NewType:    newChild.Type,
+				OldIndex:   -1,
+				NewIndex:   j,
+				NewAligned: newChild.isAligned(),
+			})
+			continue
+		}
+
+		oldChild := &oldTree.Children[i]
+		change := FieldChange{
+			Path:       fieldPath(newChild.Name),
+			OldType:    oldChild.Type,
+			NewType:    newChild.Type,
+			OldIndex:   i,
+			NewIndex:   j,
+			OldAligned: oldChild.isAligned(),
+			NewAligned: newChild.isAligned(),
+		}
+
+		if !inOrder[newChild.Name] {
+			change.Kind = FieldReordered
+			changes = append(changes, change)
+		}
+
+		if oldChild.Type != newChild.Type || oldChild.IsArray != newChild.IsArray {
+			change.Kind = FieldRetyped
+			changes = append(changes, change)
+			continue
+		}
+
+		if change.OldAligned != change.NewAligned {
+			change.Kind = FieldAlignmentChanged
+			changes = append(changes, change)
+		}
+
+		changes = diffTypeTreeChildren(changes, change.Path, oldChild, newChild)
+	}
+	return changes
+}
+
+// longestCommonSubsequence aとbの最長共通部分列に含まれる要素を返す
+func longestCommonSubsequence(a, b []string) map[string]bool {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	common := map[string]bool{}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			common[a[i]] = true
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return common
+}
+
+// ClassType 比較するクラスの型
+// MonoBehaviour等のスクリプトの型は同じClassIDでもScriptIDで区別する
+type ClassType struct {
+	ScriptID []byte
+	TypeTree *TypeTree
+}
+
+// ClassTypes type treeを持つ型をScriptIDと組にして返す
+func (f *SerializedFile) ClassTypes() []ClassType {
+	typeMetadata := f.TypeMetadata
+	if typeMetadata == nil || !typeMetadata.HasTypeTrees {
+		return []ClassType{}
+	}
+
+	classTypes := []ClassType{}
+	for i := range typeMetadata.TypeTrees {
+		classType := ClassType{TypeTree: &typeMetadata.TypeTrees[i]}
+		if i < len(typeMetadata.Hashes) {
+			classType.ScriptID = typeMetadata.Hashes[i].ScriptID
+		}
+		classTypes = append(classTypes, classType)
+	}
+	return classTypes
+}
+
+// classTypeKey DiffTypeTreesで型を対応付けるキー
+type classTypeKey struct {
+	classID  ClassID
+	scriptID string
+}
+
+// classLayouts 同じキーを持つ型のレイアウト (型ハッシュで重複を除いたもの)
+type classLayouts struct {
+	hashes    []string
+	typeTrees []*TypeTree
+}
+
+func classTypesByKey(classTypes []ClassType) map[classTypeKey]*classLayouts {
+	byKey := map[classTypeKey]*classLayouts{}
+	for _, classType := range classTypes {
+		key := classTypeKey{classID: classType.TypeTree.ClassID, scriptID: hex.EncodeToString(classType.ScriptID)}
+		layouts, ok := byKey[key]
+		if !ok {
+			layouts = &classLayouts{}
+			byKey[key] = layouts
+		}
+
+		hash := string(ComputeTypeHash(classType.TypeTree))
+		found := false
+		for _, h := range layouts.hashes {
+			if h == hash {
+				found = true
+				break
+			}
+		}
+		if !found {
+			layouts.hashes = append(layouts.hashes, hash)
+			layouts.typeTrees = append(layouts.typeTrees, classType.TypeTree)
+		}
+	}
+	return byKey
+}
+
+// sameLayouts 2つのレイアウトの集合が同じかどうか
+func sameLayouts(a, b *classLayouts) bool {
+	if len(a.hashes) != len(b.hashes) {
+		return false
+	}
+	for _, x := range a.hashes {
+		found := false
+		for _, y := range b.hashes {
+			if x == y {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// DiffTypeTrees 2つの型の集合をClassIDとScriptIDで対応付けて比較し、変更のあるクラスをClassID、ScriptID順に返す
+// 同じClassIDとScriptIDで異なるレイアウトが複数ある場合は、どれを比べるか決められないためClassAmbiguousとして返す
+func DiffTypeTrees(oldTypes, newTypes []ClassType) []ClassDiff {
+	oldByKey := classTypesByKey(oldTypes)
+	newByKey := classTypesByKey(newTypes)
+
+	keys := []classTypeKey{}
+	for key := range oldByKey {
+		keys = append(keys, key)
+	}
+	for key := range newByKey {
+		if _, ok := oldByKey[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].classID != keys[j].classID {
+			return keys[i].classID < keys[j].classID
+		}
+		return keys[i].scriptID < keys[j].scriptID
+	})
+
+	diffs := []ClassDiff{}
+	for _, key := range keys {
+		oldLayouts, inOld := oldByKey[key]
+		newLayouts, inNew := newByKey[key]
+		diff := ClassDiff{ClassID: key.classID, ScriptID: key.scriptID}
+
+		switch {
+		case !inNew:
+			diff.Type = oldLayouts.typeTrees[0].Type
+			diff.Status = ClassRemoved
+		case !inOld:
+			diff.Type = newLayouts.typeTrees[0].Type
+			diff.Status = ClassAdded
+		case sameLayouts(oldLayouts, newLayouts):
+			continue
+		case len(oldLayouts.typeTrees) > 1 || len(newLayouts.typeTrees) > 1:
+			diff.Type = newLayouts.typeTrees[0].Type
+			diff.Status = ClassAmbiguous
+			diff.OldLayouts = len(oldLayouts.typeTrees)
+			diff.NewLayouts = len(newLayouts.typeTrees)
+		default:
+			diff.Type = newLayouts.typeTrees[0].Type
+			diff.Status = ClassChanged
+			diff.Changes = DiffTypeTree(oldLayouts.typeTrees[0], newLayouts.typeTrees[0])
+			if len(diff.Changes) == 0 {
+				continue
+			}
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+// WriteTypeTreeDiffText 比較結果をテキストで書き出す
+func WriteTypeTreeDiffText(w io.Writer, diffs []ClassDiff) error {
+	alignment := func(aligned bool) string {
+		if aligned {
+			return "aligned"
+		}
+		return "unaligned"
+	}
+
+	for _, diff := range diffs {
+		name := diff.Type
+		if diff.ScriptID != "" {
+			name += " (ScriptID " + diff.ScriptID + ")"
+		}
+		status := diff.Status
+		if diff.Status == ClassAmbiguous {
+			status += fmt.Sprintf(" (%d old layouts, %d new layouts)", diff.OldLayouts, diff.NewLayouts)
+		}
+
+		_, err := fmt.Fprintf(w, "ClassID %d %s: %s\n", diff.ClassID, name, status)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range diff.Changes {
+			var line string
+			switch change.Kind {
+			case FieldAdded:
+				line = fmt.Sprintf("%s (%s)", change.Path, change.NewType)
+			case FieldRemoved:
+				line = fmt.Sprintf("%s (%s)", change.Path, change.OldType)
+			case FieldRetyped:
+				line = fmt.Sprintf("%s: %s -> %s", change.Path, change.OldType, change.NewType)
+			case FieldReordered:
+				line = fmt.Sprintf("%s: %d -> %d", change.Path, change.OldIndex, change.NewIndex)
+			case FieldAlignmentChanged:
+				line = fmt.Sprintf("%s: %s -> %s", change.Path, alignment(change.OldAligned), alignment(change.NewAligned))
+			}
+
+			_, err = fmt.Fprintf(w, "\t%-9s %s\n", change.Kind, line)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package unity
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDiffTypeTrees(t *testing.T) {
+	oldTree := testTypeTree()
+	oldTree.ClassID = 1001
+	newTree := testTypeTree()
+	newTree.ClassID = 1001
+
+	// m_Enabled: アラインを外す、m_Float: 型をdoubleに変更、m_Ints: 削除、m_Added: 追加
+	// m_Map.Array.data.second: 型を変更、m_Blob: m_Nameの前に移動
+	children := newTree.Children
+	children[1].Flags = 0
+	children[5].Type = "double"
+	children[3].Children[0].Children[1].Children[1].Type = "int"
+	newTree.Children = []TypeTree{children[4], children[0], children[1], children[3], children[5], {Type: "int", Name: "m_Added", Size: 4}}
+
+	removed := &TypeTree{ClassID: 1, Type: "GameObject"}
+	added := &TypeTree{ClassID: 4, Type: "Transform"}
+
+	diffs := DiffTypeTrees([]ClassType{{TypeTree: removed}, {TypeTree: oldTree}}, []ClassType{{TypeTree: newTree}, {TypeTree: added}})
+	if len(diffs) != 3 {
+		t.Fatalf("unexpected diffs: %+v", diffs)
+	}
+	if diffs[0].Status != ClassRemoved || diffs[1].Status != ClassAdded || diffs[2].Status != ClassChanged {
+		t.Fatalf("unexpected class status: %+v", diffs)
+	}
+
+	kinds := map[string]string{}
+	for _, change := range diffs[2].Changes {
+		kinds[change.Path] += change.Kind + " "
+	}
+	want := map[string]string{
+		"m_Ints":                  "removed ",
+		"m_Blob":                  "reordered ",
+		"m_Enabled":               "alignment ",
+		"m_Map.Array.data.second": "retyped ",
+		"m_Float":                 "retyped ",
+		"m_Added":                 "added ",
+	}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("unexpected changes:\n got %v\nwant %v", kinds, want)
+	}
+
+	var buf bytes.Buffer
+	err := WriteTypeTreeDiffText(&buf, diffs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("ClassID 1001 Test: changed\n")) ||
+		!bytes.Contains(buf.Bytes(), []byte("\tretyped   m_Float: float -> double\n")) {
+		t.Fatalf("unexpected text output:\n%s", buf.String())
+	}
+
+	if len(DiffTypeTree(oldTree, testTypeTree())) != 0 {
+		t.Fatal("identical type trees should have no changes")
+	}
+}
+
+func TestDiffTypeTreesScriptTypes(t *testing.T) {
+	scriptType := func(scriptID byte, fields ...string) ClassType {
+		typeTree := &TypeTree{ClassID: monoBehaviourClassID, Type: "MonoBehaviour", Name: "Base"}
+		for _, field := range fields {
+			typeTree.Children = append(typeTree.Children, TypeTree{Type: "int", Name: field, Size: 4})
+		}
+		return ClassType{ScriptID: bytes.Repeat([]byte{scriptID}, 16), TypeTree: typeTree}
+	}
+
+	// 同じClassIDでもScriptIDごとに比較する
+	diffs := DiffTypeTrees(
+		[]ClassType{scriptType(1, "m_A"), scriptType(2, "m_B"), scriptType(2, "m_B")},
+		[]ClassType{scriptType(2, "m_B", "m_C"), scriptType(1, "m_A")},
+	)
+	if len(diffs) != 1 {
+		t.Fatalf("unexpected diffs: %+v", diffs)
+	}
+	if diffs[0].ScriptID != "02020202020202020202020202020202" || diffs[0].Status != ClassChanged ||
+		len(diffs[0].Changes) != 1 || diffs[0].Changes[0].Path != "m_C" {
+		t.Fatalf("unexpected diff: %+v", diffs[0])
+	}
+
+	// ScriptIDが無いため同じキーに異なるレイアウトが複数ある場合は、最初のものを選ばずに報告する
+	withoutScriptID := func(classType ClassType) ClassType {
+		classType.ScriptID = nil
+		return classType
+	}
+	diffs = DiffTypeTrees(
+		[]ClassType{withoutScriptID(scriptType(1, "m_A")), withoutScriptID(scriptType(2, "m_B"))},
+		[]ClassType{withoutScriptID(scriptType(1, "m_A"))},
+	)
+	if len(diffs) != 1 || diffs[0].Status != ClassAmbiguous || diffs[0].OldLayouts != 2 || diffs[0].NewLayouts != 1 {
+		t.Fatalf("unexpected diffs: %+v", diffs)
+	}
+
+	var buf bytes.Buffer
+	err := WriteTypeTreeDiffText(&buf, diffs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "ClassID 114 MonoBehaviour: ambiguous (2 old layouts, 1 new layouts)\n" {
+		t.Fatalf("unexpected text output:\n%s", buf.String())
+	}
+}
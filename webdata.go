@@ -0,0 +1,163 @@
+package unity
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+
+	"github.com/andybalholm/brotli"
+)
+
+// SignatureUnityWebData WebGLのデータパッケージ (.data / .unityweb) のシグネチャ
+const SignatureUnityWebData = "UnityWebData1.0"
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Unityが書き出すbrotliストリームはメタデータに "UnityWeb Compressed Content (brotli)" を含む
+var brotliMagic = []byte("brotli")
+
+const brotliMagicOffset = 0x20
+
+// WebData WebGLのデータパッケージ
+type WebData struct {
+	Signature  string
+	HeaderSize uint32
+	Files      []WebDataFile
+	data       []byte
+}
+
+// WebDataFile パッケージ内のファイル
+type WebDataFile struct {
+	Offset uint32
+	Size   uint32
+	Name   string
+}
+
+// decompressWebData gzip / brotliで包まれている場合は展開する
+func decompressWebData(b []byte) ([]byte, error) {
+	if bytes.HasPrefix(b, gzipMagic) {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+
+	if len(b) >= brotliMagicOffset+len(brotliMagic) && bytes.Equal(b[brotliMagicOffset:brotliMagicOffset+len(brotliMagic)], brotliMagic) {
+		return ioutil.ReadAll(brotli.NewReader(bytes.NewReader(b)))
+	}
+
+	return b, nil
+}
+
+// ParseWebData WebGLのデータパッケージをパース
+func ParseWebData(b []byte) (*WebData, error) {
+	data, err := decompressWebData(b)
+	if err != nil {
+		return nil, err
+	}
+
+	webData := WebData{
+		data: data,
+	}
+
+	dataReader, err := NewDataReader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := dataReader.ReadStringNull(256)
+	if err != nil {
+		return nil, err
+	}
+	if signature != SignatureUnityWebData {
+		return nil, ErrInvalidWebDataType
+	}
+	webData.Signature = signature
+
+	headerSize, err := dataReader.ReadUint(true)
+	if err != nil {
+		return nil, err
+	}
+	webData.HeaderSize = headerSize
+
+	files := []WebDataFile{}
+	for dataReader.Pos() < int64(headerSize) {
+		offset, err := dataReader.ReadUint(true)
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := dataReader.ReadUint(true)
+		if err != nil {
+			return nil, err
+		}
+
+		nameSize, err := dataReader.ReadUint(true)
+		if err != nil {
+			return nil, err
+		}
+
+		if int64(nameSize) > int64(headerSize)-dataReader.Pos() {
+			return nil, ErrInvalidWebDataType
+		}
+
+		name, err := dataReader.ReadBytes(int(nameSize), true)
+		if err != nil {
+			return nil, err
+		}
+
+		if uint64(offset)+uint64(size) > uint64(len(data)) {
+			return nil, ErrInvalidNodeRange
+		}
+
+		files = append(files, WebDataFile{
+			Offset: offset,
+			Size:   size,
+			Name:   string(name),
+		})
+	}
+	webData.Files = files
+
+	return &webData, nil
+}
+
+// OpenWebData ファイルパスからWebGLのデータパッケージを開く
+func OpenWebData(path string) (*WebData, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseWebData(b)
+}
+
+// ReadFile パッケージ内のファイルの内容を返す
+func (w *WebData) ReadFile(name string) ([]byte, error) {
+	for _, f := range w.Files {
+		if f.Name == name {
+			return w.data[f.Offset : f.Offset+f.Size], nil
+		}
+	}
+	return nil, ErrFileNotFound
+}
+
+// OpenBundle パッケージ内のBundle (data.unity3d等) を開く
+func (w *WebData) OpenBundle(name string) (*Bundle, error) {
+	b, err := w.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return OpenBundle(bytes.NewReader(b), int64(len(b)))
+}
+
+// IsBundle パッケージ内のファイルがBundleかどうか
+func (w *WebData) IsBundle(name string) bool {
+	b, err := w.ReadFile(name)
+	if err != nil {
+		return false
+	}
+
+	i := bytes.IndexByte(b, 0)
+	return i >= 0 && isBundleSignature(string(b[:i]))
+}
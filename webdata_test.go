@@ -0,0 +1,91 @@
+package unity
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"testing"
+)
+
+// testWebData UnityWebData1.0形式のパッケージを組み立てる
+// offsetsがnilでない場合はエントリのオフセットをその値で上書きする
+func testWebData(names []string, files [][]byte, offsets []uint32) []byte {
+	headerSize := len(SignatureUnityWebData) + 1 + 4
+	for _, name := range names {
+		headerSize += 12 + len(name)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(SignatureUnityWebData + "\x00")
+	binary.Write(&buf, binary.LittleEndian, uint32(headerSize))
+	offset := uint32(headerSize)
+	for i, name := range names {
+		entryOffset := offset
+		if offsets != nil {
+			entryOffset = offsets[i]
+		}
+		binary.Write(&buf, binary.LittleEndian, entryOffset)
+		binary.Write(&buf, binary.LittleEndian, uint32(len(files[i])))
+		binary.Write(&buf, binary.LittleEndian, uint32(len(name)))
+		buf.WriteString(name)
+		offset += uint32(len(files[i]))
+	}
+	for _, file := range files {
+		buf.Write(file)
+	}
+	return buf.Bytes()
+}
+
+func TestParseWebData(t *testing.T) {
+	names := []string{"sharedassets0.assets", "sharedassets0.assets.resS"}
+	files := [][]byte{bytes.Repeat([]byte("serialized"), 100), []byte("0123456789")}
+	plain := testWebData(names, files, nil)
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(plain)
+	w.Close()
+
+	for i, b := range [][]byte{plain, gz.Bytes()} {
+		webData, err := ParseWebData(b)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if len(webData.Files) != len(names) {
+			t.Fatalf("case %d: unexpected number of files: %d", i, len(webData.Files))
+		}
+		for j, f := range webData.Files {
+			if f.Name != names[j] || int(f.Size) != len(files[j]) {
+				t.Fatalf("case %d: unexpected file entry: %+v", i, f)
+			}
+			data, err := webData.ReadFile(f.Name)
+			if err != nil {
+				t.Fatalf("case %d: %v", i, err)
+			}
+			if !bytes.Equal(data, files[j]) {
+				t.Fatalf("case %d: %s の内容が一致しません", i, f.Name)
+			}
+		}
+		if webData.IsBundle(names[0]) {
+			t.Fatalf("case %d: serialized file detected as bundle", i)
+		}
+		_, err = webData.ReadFile("data.unity3d")
+		if err != ErrFileNotFound {
+			t.Fatalf("case %d: expected ErrFileNotFound, got %v", i, err)
+		}
+	}
+
+	// データ外を指すオフセット
+	_, err := ParseWebData(testWebData(names, files, []uint32{0, uint32(len(plain))}))
+	if err != ErrInvalidNodeRange {
+		t.Fatalf("expected ErrInvalidNodeRange, got %v", err)
+	}
+
+	// ヘッダを超える名前の長さ
+	broken := append([]byte{}, plain...)
+	binary.LittleEndian.PutUint32(broken[len(SignatureUnityWebData)+1+4+8:], 0xffffffff)
+	_, err = ParseWebData(broken)
+	if err != ErrInvalidWebDataType {
+		t.Fatalf("expected ErrInvalidWebDataType, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package unity
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// DataWriter Unityファイル用バイナリライター
+type DataWriter struct {
+	buffer *bytes.Buffer
+}
+
+// NewDataWriter new DataWriter instance
+func NewDataWriter() *DataWriter {
+	return &DataWriter{
+		&bytes.Buffer{},
+	}
+}
+
+// WriteByte write 1 byte
+func (data *DataWriter) WriteByte(c byte) error {
+	return data.buffer.WriteByte(c)
+}
+
+// WriteChar write char
+func (data *DataWriter) WriteChar(i int8, isLittleEndian bool) error {
+	return binary.Write(data.buffer, selectByteOrder(isLittleEndian), i)
+}
+
+// WriteUchar write unsigned char
+func (data *DataWriter) WriteUchar(i uint8, isLittleEndian bool) error {
+	return binary.Write(data.buffer, selectByteOrder(isLittleEndian), i)
+}
+
+// WriteShort write short
+func (data *DataWriter) WriteShort(i int16, isLittleEndian bool) error {
+	return binary.Write(data.buffer, selectByteOrder(isLittleEndian), i)
+}
+
+// WriteUshort write unsigned short
+func (data *DataWriter) WriteUshort(i uint16, isLittleEndian bool) error {
+	return binary.Write(data.buffer, selectByteOrder(isLittleEndian), i)
+}
+
+// WriteInt write int
+func (data *DataWriter) WriteInt(i int32, isLittleEndian bool) error {
+	return binary.Write(data.buffer, selectByteOrder(isLittleEndian), i)
+}
+
+// WriteUint write unsigned int
+func (data *DataWriter) WriteUint(i uint32, isLittleEndian bool) error {
+	return binary.Write(data.buffer, selectByteOrder(isLittleEndian), i)
+}
+
+// WriteLong write long
+func (data *DataWriter) WriteLong(i int64, isLittleEndian bool) error {
+	return binary.Write(data.buffer, selectByteOrder(isLittleEndian), i)
+}
+
+// WriteUlong write unsigned long
+func (data *DataWriter) WriteUlong(i uint64, isLittleEndian bool) error {
+	return binary.Write(data.buffer, selectByteOrder(isLittleEndian), i)
+}
+
+// WriteBytes write bytes
+func (data *DataWriter) WriteBytes(b []byte) error {
+	_, err := data.buffer.Write(b)
+	return err
+}
+
+// WriteStringNull 文字列と終端の0x00を書き込む
+func (data *DataWriter) WriteStringNull(s string) error {
+	_, err := data.buffer.WriteString(s)
+	if err != nil {
+		return err
+	}
+	return data.buffer.WriteByte(0)
+}
+
+// Align 4バイト境界まで0x00で埋める
+func (data *DataWriter) Align() error {
+	return data.AlignTo(4)
+}
+
+// AlignTo nバイト境界まで0x00で埋める (nは2の累乗)
+func (data *DataWriter) AlignTo(n int) error {
+	oldPos := data.buffer.Len()
+	newPos := (oldPos + n - 1) & -n
+	_, err := data.buffer.Write(make([]byte, newPos-oldPos))
+	return err
+}
+
+// Len 書き込み済みのバイト数
+func (data *DataWriter) Len() int {
+	return data.buffer.Len()
+}
+
+// Bytes 書き込み済みのデータ
+func (data *DataWriter) Bytes() []byte {
+	return data.buffer.Bytes()
+}